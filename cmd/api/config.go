@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/ratelimit"
+)
+
+func getEnvString(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvUint(key string, fallback uint64, bitSize int) uint64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return parsed
+}
+
+func mustGetEnv(key string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		log.Fatalf("The environment variable %s is required", key)
+	}
+	return value
+}
+
+func getEnvLimit(key string, fallback string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(getEnvString(key, fallback))
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return limit
+}
+
+// getOidcProviders reads the providers listed in OIDC_PROVIDERS. Each of them
+// is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and optionally
+// OIDC_<NAME>_SCOPES.
+func getOidcProviders(ctx context.Context) map[string]*auth.OidcProvider {
+	providers := make(map[string]*auth.OidcProvider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers[name] = auth.NewOidcProvider(ctx, auth.OidcProviderConfig{
+			Issuer:       mustGetEnv(prefix + "ISSUER"),
+			ClientID:     mustGetEnv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  mustGetEnv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(getEnvString(prefix+"SCOPES", "email profile")),
+		})
+	}
+	return providers
+}
+
+// getPasswordPolicy reads the requirements for new passwords. The blocklist
+// that ships with the auth package is replaced by PASSWORD_BLOCKLIST_FILE if
+// it is set.
+func getPasswordPolicy() auth.PasswordPolicy {
+	policy := auth.PasswordPolicy{
+		MinLength:  int(getEnvUint("PASSWORD_MIN_LENGTH", uint64(auth.DefaultPasswordPolicy.MinLength), 8)),
+		MinClasses: int(getEnvUint("PASSWORD_MIN_CLASSES", uint64(auth.DefaultPasswordPolicy.MinClasses), 8)),
+		MinEntropy: float64(getEnvUint("PASSWORD_MIN_ENTROPY", uint64(auth.DefaultPasswordPolicy.MinEntropy), 16)),
+		Blocklist:  auth.DefaultPasswordPolicy.Blocklist,
+	}
+
+	if path := os.Getenv("PASSWORD_BLOCKLIST_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Invalid value for PASSWORD_BLOCKLIST_FILE: %v", err)
+		}
+		defer file.Close()
+
+		policy.Blocklist, err = auth.ReadPasswordBlocklist(file)
+		if err != nil {
+			log.Fatalf("Invalid value for PASSWORD_BLOCKLIST_FILE: %v", err)
+		}
+	}
+
+	return policy
+}
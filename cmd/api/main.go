@@ -2,17 +2,26 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"github.com/mderler/simple-go-backend/internal/auth"
 	"github.com/mderler/simple-go-backend/internal/db"
 	"github.com/mderler/simple-go-backend/internal/handlers"
+	"github.com/mderler/simple-go-backend/internal/jobs"
+	"github.com/mderler/simple-go-backend/internal/mailer"
+	"github.com/mderler/simple-go-backend/internal/notifier"
+	"github.com/mderler/simple-go-backend/internal/ratelimit"
+	"github.com/mderler/simple-go-backend/internal/storage"
 
 	_ "github.com/mderler/simple-go-backend/docs"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
@@ -23,6 +32,11 @@ import (
 // @description This is a sample API Server.
 // @license.name MIT
 // @BasePath /v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the access token, or "ApiKey" followed by a space and an API key.
+// @description Browsers can use the session cookie of /auth/session instead and send its CSRF token in the X-CSRF-Token header.
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatal("Error loading .env file")
@@ -46,15 +60,216 @@ func main() {
 
 	queries := db.New(conn)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	passwords := auth.NewPasswordHasher(auth.Argon2Params{
+		Memory:      uint32(getEnvUint("ARGON2_MEMORY", uint64(auth.DefaultArgon2Params.Memory), 32)),
+		Iterations:  uint32(getEnvUint("ARGON2_ITERATIONS", uint64(auth.DefaultArgon2Params.Iterations), 32)),
+		Parallelism: uint8(getEnvUint("ARGON2_PARALLELISM", uint64(auth.DefaultArgon2Params.Parallelism), 8)),
+		SaltLength:  auth.DefaultArgon2Params.SaltLength,
+		KeyLength:   auth.DefaultArgon2Params.KeyLength,
+	})
+
+	handlers.SetPasswordPolicy(getPasswordPolicy())
+
+	tokens := auth.NewTokenManager(
+		[]byte(mustGetEnv("JWT_SECRET")),
+		getEnvString("JWT_ISSUER", "simple-go-backend"),
+		getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	)
+	sessions := handlers.SessionConfig{
+		CookieName: getEnvString("SESSION_COOKIE_NAME", "session"),
+		TTL:        getEnvDuration("SESSION_TTL", 24*time.Hour),
+		Secure:     getEnvString("SESSION_COOKIE_SECURE", "true") == "true",
+	}
+	authn := handlers.NewAuthenticator(queries, tokens, sessions)
+
+	totpKey, err := base64.StdEncoding.DecodeString(mustGetEnv("TOTP_ENCRYPTION_KEY"))
+	if err != nil {
+		log.Fatalf("Invalid value for TOTP_ENCRYPTION_KEY: %v", err)
+	}
+	totp, err := auth.NewTotpManager(totpKey, getEnvString("TOTP_ISSUER", "simple-go-backend"))
+	if err != nil {
+		log.Fatalf("Invalid value for TOTP_ENCRYPTION_KEY: %v", err)
+	}
+
+	go jobs.Every(ctx, "delete expired refresh tokens", getEnvDuration("TOKEN_CLEANUP_INTERVAL", time.Hour), func(ctx context.Context) error {
+		deleted, err := queries.DeleteExpiredRefreshTokens(ctx)
+		if deleted > 0 {
+			log.Printf("Deleted %d expired refresh tokens\n", deleted)
+		}
+		return err
+	})
+
+	go jobs.Every(ctx, "delete expired sessions", getEnvDuration("TOKEN_CLEANUP_INTERVAL", time.Hour), func(ctx context.Context) error {
+		deleted, err := queries.DeleteExpiredSessions(ctx)
+		if deleted > 0 {
+			log.Printf("Deleted %d expired sessions\n", deleted)
+		}
+		return err
+	})
+
+	go jobs.Every(ctx, "delete expired password reset tokens", getEnvDuration("TOKEN_CLEANUP_INTERVAL", time.Hour), func(ctx context.Context) error {
+		deleted, err := queries.DeleteExpiredPasswordResetTokens(ctx)
+		if deleted > 0 {
+			log.Printf("Deleted %d expired password reset tokens\n", deleted)
+		}
+		return err
+	})
+
+	var mail mailer.Mailer
+	switch backend := getEnvString("MAILER", "stdout"); backend {
+	case "smtp":
+		mail = mailer.NewSMTPMailer(
+			mustGetEnv("SMTP_HOST"),
+			getEnvString("SMTP_PORT", "587"),
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+			mustGetEnv("MAIL_FROM"),
+		)
+	case "stdout":
+		mail = mailer.NewWriterMailer(os.Stdout)
+	case "file":
+		file, err := os.OpenFile(getEnvString("MAILER_FILE", "mail.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatalf("Error opening mail file: %v", err)
+		}
+		defer file.Close()
+		mail = mailer.NewWriterMailer(file)
+	default:
+		log.Fatalf("Unknown mailer %s", backend)
+	}
+	var notify notifier.Notifier
+	switch backend := getEnvString("NOTIFIER", "log"); backend {
+	case "log":
+		notify = notifier.NewLogNotifier(log.Default())
+	case "mail":
+		notify = notifier.NewMailNotifier(mail)
+	default:
+		log.Fatalf("Unknown notifier %s", backend)
+	}
+	reminders := notifier.NewReminderScheduler(queries, notify)
+
+	go jobs.Every(ctx, "send todo reminders", getEnvDuration("REMINDER_INTERVAL", time.Minute), reminders.SendDue)
+
+	subtasks := handlers.SubtaskConfig{
+		MaxDepth:            int32(getEnvUint("TODO_MAX_DEPTH", 5, 31)),
+		AutoCompleteParents: getEnvString("TODO_AUTO_COMPLETE_PARENTS", "false") == "true",
+	}
+
+	var blobs storage.BlobStore
+	switch backend := getEnvString("BLOB_STORE", "local"); backend {
+	case "local":
+		blobs, err = storage.NewLocalStore(getEnvString("BLOB_DIR", "data/attachments"))
+	case "s3":
+		blobs, err = storage.NewS3Store(
+			getEnvString("S3_ENDPOINT", "http://localhost:9000"),
+			getEnvString("S3_BUCKET", "attachments"),
+			getEnvString("S3_REGION", "us-east-1"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+		)
+	default:
+		log.Fatalf("Unknown blob store %s", backend)
+	}
+	if err != nil {
+		log.Fatalf("Unable to create blob store: %v\n", err)
+	}
+	attachments := handlers.AttachmentConfig{
+		MaxSize:      int64(getEnvUint("ATTACHMENT_MAX_SIZE", 10<<20, 63)),
+		AllowedTypes: strings.Split(getEnvString("ATTACHMENT_ALLOWED_TYPES", "image/png,image/jpeg,image/gif,image/webp,application/pdf,text/plain"), ","),
+	}
+	orphans := storage.NewOrphanCollector(queries, blobs)
+
+	go jobs.Every(ctx, "delete orphaned attachment blobs", getEnvDuration("ATTACHMENT_CLEANUP_INTERVAL", 5*time.Minute), orphans.DeleteOrphans)
+
+	verifier := handlers.NewEmailVerifier(tokens, mail, getEnvString("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"))
+	resets := handlers.PasswordResetConfig{
+		URL: getEnvString("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		TTL: getEnvDuration("PASSWORD_RESET_TTL", time.Hour),
+	}
+
+	lockoutBaseDelay := getEnvDuration("LOGIN_LOCKOUT_BASE_DELAY", time.Minute)
+	lockoutMaxDelay := getEnvDuration("LOGIN_LOCKOUT_MAX_DELAY", time.Hour)
+	lockoutWindow := getEnvDuration("LOGIN_LOCKOUT_WINDOW", 24*time.Hour)
+	guard := handlers.NewLoginGuard(queries,
+		handlers.LockoutPolicy{
+			Threshold: int32(getEnvUint("LOGIN_LOCKOUT_THRESHOLD", 5, 31)),
+			BaseDelay: lockoutBaseDelay,
+			MaxDelay:  lockoutMaxDelay,
+			Window:    lockoutWindow,
+		},
+		handlers.LockoutPolicy{
+			Threshold: int32(getEnvUint("LOGIN_IP_LOCKOUT_THRESHOLD", 50, 31)),
+			BaseDelay: lockoutBaseDelay,
+			MaxDelay:  lockoutMaxDelay,
+			Window:    lockoutWindow,
+		},
+	)
+
+	go jobs.Every(ctx, "delete stale failed logins", getEnvDuration("TOKEN_CLEANUP_INTERVAL", time.Hour), func(ctx context.Context) error {
+		deleted, err := guard.Cleanup(ctx)
+		if deleted > 0 {
+			log.Printf("Deleted %d stale failed logins\n", deleted)
+		}
+		return err
+	})
+
+	providers := getOidcProviders(ctx)
+
+	go jobs.Every(ctx, "delete expired OIDC login requests", getEnvDuration("TOKEN_CLEANUP_INTERVAL", time.Hour), func(ctx context.Context) error {
+		deleted, err := queries.DeleteExpiredOidcAuthRequests(ctx)
+		if deleted > 0 {
+			log.Printf("Deleted %d expired OIDC login requests\n", deleted)
+		}
+		return err
+	})
+
+	var rateLimitStore ratelimit.Store
+	switch backend := getEnvString("RATE_LIMIT_BACKEND", "memory"); backend {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitStore = ratelimit.NewPostgresStore(queries)
+	default:
+		log.Fatalf("Unknown rate limit backend %s", backend)
+	}
+	limiter := handlers.NewRateLimiter(rateLimitStore, map[string]ratelimit.Limit{
+		"global":          getEnvLimit("RATE_LIMIT_GLOBAL", "300/1m"),
+		"auth":            getEnvLimit("RATE_LIMIT_AUTH", "10/1m"),
+		"user":            getEnvLimit("RATE_LIMIT_USER", "60/1m"),
+		"todo":            getEnvLimit("RATE_LIMIT_TODO", "120/1m"),
+		"todo:create":     getEnvLimit("RATE_LIMIT_TODO_CREATE", "30/1m"),
+		"audit":           getEnvLimit("RATE_LIMIT_AUDIT", "60/1m"),
+		"label":           getEnvLimit("RATE_LIMIT_LABEL", "60/1m"),
+		"todo:attachment": getEnvLimit("RATE_LIMIT_TODO_ATTACHMENT", "20/1m"),
+	})
+
+	go jobs.Every(ctx, "delete idle rate limit buckets", 10*time.Minute, func(ctx context.Context) error {
+		_, err := rateLimitStore.Cleanup(ctx, time.Hour)
+		return err
+	})
+
 	r := chi.NewRouter()
 
-	r.Use(middleware.AllowContentType("application/json"))
+	if getEnvString("TRUST_PROXY_HEADERS", "false") == "true" {
+		r.Use(middleware.RealIP)
+	}
+	r.Use(handlers.AllowContentType([]string{"application/json", "application/merge-patch+json"}, map[string][]string{
+		"/v1/todo/*/attachments": {"multipart/form-data"},
+	}))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
 	r.Route("/v1", func(r chi.Router) {
-		r.Mount("/user", handlers.NewUserHandler(queries))
-		r.Mount("/todo", handlers.NewTodoHandler(queries))
+		r.Use(limiter.Limit("global"))
+		r.Mount("/auth", handlers.NewAuthHandler(conn, queries, passwords, tokens, totp, mail, resets, guard, providers, sessions, authn, limiter))
+		r.Mount("/user", handlers.NewUserHandler(conn, queries, passwords, totp, verifier, authn, limiter))
+		r.Mount("/todo", handlers.NewTodoHandler(conn, queries, blobs, subtasks, attachments, authn, limiter))
+		r.Mount("/label", handlers.NewLabelHandler(conn, queries, authn, limiter))
+		r.Mount("/audit", handlers.NewAuditHandler(queries, authn, limiter))
 	})
 
 	r.Get("/swagger/*", httpSwagger.Handler(
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"strings"
+)
+
+const apiKeyScheme = "sgb"
+
+// NewApiKey returns a new API key of the form sgb_<prefix>_<secret>, the
+// prefix used to look it up and the hash that should be stored in place of
+// it.
+func NewApiKey() (key string, prefix string, hash string, err error) {
+	prefix, err = RandomID()
+	if err != nil {
+		return "", "", "", err
+	}
+	prefix = prefix[:12]
+
+	secret, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = apiKeyScheme + "_" + prefix + "_" + secret
+	return key, prefix, HashOpaqueToken(key), nil
+}
+
+// ParseApiKey returns the lookup prefix of the API key. ok is false if the
+// key is malformed.
+func ParseApiKey(key string) (prefix string, ok bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyScheme || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
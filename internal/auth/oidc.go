@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var ErrInvalidOidcResponse = errors.New("the response of the identity provider is invalid")
+
+// OidcIdentity holds the claims of an ID token that are needed to find or
+// create the local user.
+type OidcIdentity struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// OidcProviderConfig holds the settings of an OpenID Connect provider.
+type OidcProviderConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// OidcProvider signs users in through an OpenID Connect provider with the
+// authorization code flow and PKCE. The discovery document of the provider is
+// fetched on first use, so the API still starts while a provider is down.
+type OidcProvider struct {
+	// ctx is kept for the discovery because the provider fetches its signing
+	// keys with it later on, long after the request that triggered it.
+	ctx    context.Context
+	config OidcProviderConfig
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOidcProvider(ctx context.Context, config OidcProviderConfig) *OidcProvider {
+	return &OidcProvider{ctx: ctx, config: config}
+}
+
+// OidcAuthRequest holds the values that have to be kept between redirecting
+// the user to the provider and handling the callback.
+type OidcAuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+// NewOidcAuthRequest returns random values for a new authorization request.
+func NewOidcAuthRequest() (OidcAuthRequest, error) {
+	state, err := RandomID()
+	if err != nil {
+		return OidcAuthRequest{}, err
+	}
+
+	nonce, err := RandomID()
+	if err != nil {
+		return OidcAuthRequest{}, err
+	}
+
+	return OidcAuthRequest{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+	}, nil
+}
+
+// AuthCodeURL returns the URL of the provider that the user has to be
+// redirected to.
+func (p *OidcProvider) AuthCodeURL(request OidcAuthRequest) (string, error) {
+	config, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	return config.AuthCodeURL(request.State,
+		oidc.Nonce(request.Nonce),
+		oauth2.S256ChallengeOption(request.CodeVerifier),
+	), nil
+}
+
+// Exchange redeems the authorization code of the callback and returns the
+// identity from the verified ID token.
+func (p *OidcProvider) Exchange(ctx context.Context, code string, request OidcAuthRequest) (OidcIdentity, error) {
+	config, verifier, err := p.discover()
+	if err != nil {
+		return OidcIdentity{}, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(request.CodeVerifier))
+	if err != nil {
+		return OidcIdentity{}, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return OidcIdentity{}, ErrInvalidOidcResponse
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OidcIdentity{}, err
+	}
+	if idToken.Nonce != request.Nonce {
+		return OidcIdentity{}, ErrInvalidOidcResponse
+	}
+
+	var identity OidcIdentity
+	if err := idToken.Claims(&identity); err != nil {
+		return OidcIdentity{}, err
+	}
+	if identity.Subject == "" {
+		return OidcIdentity{}, ErrInvalidOidcResponse
+	}
+
+	return identity, nil
+}
+
+func (p *OidcProvider) discover() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(p.ctx, p.config.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, p.config.Scopes...),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+
+	return p.oauth2, p.verifier, nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy describes the requirements for new passwords. Character
+// classes are lowercase letters, uppercase letters, digits and everything
+// else. MinEntropy is given in bits, see PasswordEntropy.
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int
+	MinEntropy float64
+	// Blocklist holds lowercased passwords that are too common to be used.
+	Blocklist map[string]struct{}
+}
+
+// DefaultPasswordPolicy blocks the passwords of the list that ships with
+// this package.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:  8,
+	MinClasses: 2,
+	MinEntropy: 35,
+	Blocklist:  CommonPasswords(),
+}
+
+// CommonPasswords returns the list of common and breached passwords that
+// ships with this package.
+func CommonPasswords() map[string]struct{} {
+	blocklist, _ := ReadPasswordBlocklist(strings.NewReader(commonPasswords))
+	return blocklist
+}
+
+// ReadPasswordBlocklist reads one password per line. Empty lines and lines
+// starting with # are skipped.
+func ReadPasswordBlocklist(r io.Reader) (map[string]struct{}, error) {
+	blocklist := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return blocklist, scanner.Err()
+}
+
+// LongEnough reports whether the password has at least MinLength characters.
+func (p PasswordPolicy) LongEnough(password string) bool {
+	return utf8.RuneCountInString(password) >= p.MinLength
+}
+
+// HasClasses reports whether the password uses at least MinClasses
+// character classes.
+func (p PasswordPolicy) HasClasses(password string) bool {
+	return characterClasses(password) >= p.MinClasses
+}
+
+// StrongEnough reports whether the entropy of the password reaches
+// MinEntropy.
+func (p PasswordPolicy) StrongEnough(password string) bool {
+	return PasswordEntropy(password) >= p.MinEntropy
+}
+
+// IsCommon reports whether the password is on the blocklist.
+func (p PasswordPolicy) IsCommon(password string) bool {
+	_, ok := p.Blocklist[strings.ToLower(password)]
+	return ok
+}
+
+// PasswordEntropy estimates the entropy of a password in bits as the number
+// of distinct characters times log2 of the size of the character pool. Only
+// counting distinct characters keeps repetitions like "aaaaaaaa" from scoring
+// high.
+func PasswordEntropy(password string) float64 {
+	pool := 0
+	classes := classesOf(password)
+	if classes.lower {
+		pool += 26
+	}
+	if classes.upper {
+		pool += 26
+	}
+	if classes.digit {
+		pool += 10
+	}
+	if classes.other {
+		pool += 33
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	distinct := make(map[rune]struct{})
+	for _, r := range password {
+		distinct[r] = struct{}{}
+	}
+
+	return float64(len(distinct)) * math.Log2(float64(pool))
+}
+
+// ContainsPersonalInfo reports whether the password contains one of the
+// values, ignoring case. For email addresses the local part is checked as
+// well. Values shorter than 3 characters are ignored.
+func ContainsPersonalInfo(password string, values ...string) bool {
+	password = strings.ToLower(password)
+
+	for _, value := range values {
+		value = strings.ToLower(value)
+		parts := []string{value}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			parts = append(parts, local)
+		}
+
+		for _, part := range parts {
+			if utf8.RuneCountInString(part) >= 3 && strings.Contains(password, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type passwordClasses struct {
+	lower, upper, digit, other bool
+}
+
+func classesOf(password string) passwordClasses {
+	var classes passwordClasses
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			classes.lower = true
+		case unicode.IsUpper(r):
+			classes.upper = true
+		case unicode.IsDigit(r):
+			classes.digit = true
+		default:
+			classes.other = true
+		}
+	}
+	return classes
+}
+
+func characterClasses(password string) int {
+	classes := classesOf(password)
+	count := 0
+	for _, present := range []bool{classes.lower, classes.upper, classes.digit, classes.other} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("the token is invalid or expired")
+
+const (
+	accessTokenAudience  = "access"
+	mfaTokenAudience     = "mfa"
+	verificationAudience = "email-verification"
+
+	// mfaTokenTTL limits how long the second step of a login can be delayed.
+	mfaTokenTTL = 5 * time.Minute
+	// verificationTTL gives users a bit of time to find the verification mail.
+	verificationTTL = 48 * time.Hour
+)
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+}
+
+type verificationClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+}
+
+// TokenManager issues and validates the signed JWTs handed out to clients.
+// All tokens are signed with HS256 using a single shared secret.
+type TokenManager struct {
+	secret     []byte
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenManager(secret []byte, issuer string, accessTTL time.Duration, refreshTTL time.Duration) *TokenManager {
+	return &TokenManager{secret, issuer, accessTTL, refreshTTL}
+}
+
+// AccessTTL returns how long an access token stays valid after issuing.
+func (m *TokenManager) AccessTTL() time.Duration {
+	return m.accessTTL
+}
+
+// RefreshTTL returns how long a refresh token stays valid after issuing.
+// Refresh tokens are opaque and tracked in the database, see NewOpaqueToken.
+func (m *TokenManager) RefreshTTL() time.Duration {
+	return m.refreshTTL
+}
+
+// IssueAccessToken returns a signed access token for the user. The role is
+// embedded so that it doesn't have to be looked up on every request.
+func (m *TokenManager) IssueAccessToken(userID int32, role string) (string, error) {
+	now := time.Now()
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(int64(userID), 10),
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTTL)),
+		},
+		Role: role,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// ParseAccessToken validates the access token and returns the ID and the
+// role of the user it was issued for.
+func (m *TokenManager) ParseAccessToken(token string) (int32, string, error) {
+	claims := &accessClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, m.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(accessTokenAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 32)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+	return int32(userID), claims.Role, nil
+}
+
+// MfaTTL returns how long an MFA token stays valid after issuing.
+func (m *TokenManager) MfaTTL() time.Duration {
+	return mfaTokenTTL
+}
+
+// IssueMfaToken returns a signed token that proves the user passed the
+// password check of a login. It can only be exchanged for an access token
+// together with a second factor and is rejected everywhere else.
+func (m *TokenManager) IssueMfaToken(userID int32) (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    m.issuer,
+		Subject:   strconv.FormatInt(int64(userID), 10),
+		Audience:  jwt.ClaimStrings{mfaTokenAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// ParseMfaToken validates the MFA token and returns the ID of the user it was
+// issued for.
+func (m *TokenManager) ParseMfaToken(token string) (int32, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, m.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(mfaTokenAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 32)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return int32(userID), nil
+}
+
+// VerificationTTL returns how long an email verification token stays valid
+// after issuing.
+func (m *TokenManager) VerificationTTL() time.Duration {
+	return verificationTTL
+}
+
+// IssueVerificationToken returns a signed token that confirms the email
+// address of the user once it comes back through the verification link. The
+// address is embedded so that the token stops working if it gets changed.
+func (m *TokenManager) IssueVerificationToken(userID int32, email string) (string, error) {
+	now := time.Now()
+	claims := verificationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(int64(userID), 10),
+			Audience:  jwt.ClaimStrings{verificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(verificationTTL)),
+		},
+		Email: email,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+}
+
+// ParseVerificationToken validates the email verification token and returns
+// the ID of the user and the email address it was issued for.
+func (m *TokenManager) ParseVerificationToken(token string) (int32, string, error) {
+	claims := &verificationClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, m.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(verificationAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 32)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+	return int32(userID), claims.Email, nil
+}
+
+func (m *TokenManager) keyFunc(*jwt.Token) (interface{}, error) {
+	return m.secret, nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCiphertext = errors.New("the encrypted secret is not in the correct format")
+
+const (
+	totpDigits       = 6
+	totpPeriod       = 30
+	totpSecretLength = 20
+	// totpSkew is the number of time steps before and after the current one
+	// that are still accepted to make up for clock drift.
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TotpManager creates and checks RFC 6238 time-based one-time passwords with
+// the default settings of common authenticator apps (SHA-1, 6 digits, 30
+// seconds). The shared secrets are encrypted with AES-GCM before they get
+// stored, so a leaked database alone doesn't allow to generate codes.
+type TotpManager struct {
+	aead   cipher.AEAD
+	issuer string
+}
+
+// NewTotpManager expects a key of 16, 24 or 32 bytes, which selects
+// AES-128, AES-192 or AES-256.
+func NewTotpManager(key []byte, issuer string) (*TotpManager, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TotpManager{aead, issuer}, nil
+}
+
+// NewSecret returns a random secret in base32, the way it is shown to the
+// user, together with its encrypted form for the database.
+func (m *TotpManager) NewSecret() (secret string, encrypted []byte, err error) {
+	raw := make([]byte, totpSecretLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+
+	return totpEncoding.EncodeToString(raw), m.aead.Seal(nonce, nonce, raw, nil), nil
+}
+
+// URI returns the otpauth:// URI that authenticator apps read from a QR code.
+func (m *TotpManager) URI(account string, secret string) string {
+	label := url.PathEscape(m.issuer) + ":" + url.PathEscape(account)
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", m.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Validate checks the code against the encrypted secret. A code is only
+// accepted if its time step is newer than lastStep, which prevents replaying
+// a code that was already used. On success the matched time step is returned
+// and should be stored as the new lastStep.
+func (m *TotpManager) Validate(encrypted []byte, code string, lastStep int64) (int64, bool, error) {
+	secret, err := m.decrypt(encrypted)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(code) != totpDigits {
+		return 0, false, nil
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (m *TotpManager) decrypt(encrypted []byte) ([]byte, error) {
+	nonceSize := m.aead.NonceSize()
+	if len(encrypted) < nonceSize {
+		return nil, ErrInvalidCiphertext
+	}
+
+	secret, err := m.aead.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], nil)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return secret, nil
+}
+
+// totpCode computes the HOTP value of RFC 4226 for the given counter.
+func totpCode(secret []byte, counter int64) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// NewRecoveryCodes returns a set of single-use codes in the form xxxxx-xxxxx
+// together with the hashes that get stored in the database.
+func NewRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		code := hex.EncodeToString(raw)
+		code = code[:5] + "-" + code[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code for the database lookup. Case and
+// dashes are ignored so that the code can be typed in any way.
+func HashRecoveryCode(code string) string {
+	return HashOpaqueToken(strings.ToLower(strings.ReplaceAll(code, "-", "")))
+}
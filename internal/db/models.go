@@ -5,17 +5,326 @@
 package db
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type AuditAction string
+
+const (
+	AuditActionCreate   AuditAction = "create"
+	AuditActionUpdate   AuditAction = "update"
+	AuditActionDelete   AuditAction = "delete"
+	AuditActionAssign   AuditAction = "assign"
+	AuditActionUnassign AuditAction = "unassign"
 )
 
+func (e *AuditAction) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AuditAction(s)
+	case string:
+		*e = AuditAction(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AuditAction: %T", src)
+	}
+	return nil
+}
+
+type NullAuditAction struct {
+	AuditAction AuditAction `json:"audit_action"`
+	Valid       bool        `json:"valid"` // Valid is true if AuditAction is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAuditAction) Scan(value interface{}) error {
+	if value == nil {
+		ns.AuditAction, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.AuditAction.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAuditAction) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.AuditAction), nil
+}
+
+type LockoutAction string
+
+const (
+	LockoutActionLocked   LockoutAction = "locked"
+	LockoutActionUnlocked LockoutAction = "unlocked"
+)
+
+func (e *LockoutAction) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = LockoutAction(s)
+	case string:
+		*e = LockoutAction(s)
+	default:
+		return fmt.Errorf("unsupported scan type for LockoutAction: %T", src)
+	}
+	return nil
+}
+
+type NullLockoutAction struct {
+	LockoutAction LockoutAction `json:"lockout_action"`
+	Valid         bool          `json:"valid"` // Valid is true if LockoutAction is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullLockoutAction) Scan(value interface{}) error {
+	if value == nil {
+		ns.LockoutAction, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.LockoutAction.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullLockoutAction) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.LockoutAction), nil
+}
+
+type TodoPriority string
+
+const (
+	TodoPriorityNone   TodoPriority = "none"
+	TodoPriorityLow    TodoPriority = "low"
+	TodoPriorityMedium TodoPriority = "medium"
+	TodoPriorityHigh   TodoPriority = "high"
+	TodoPriorityUrgent TodoPriority = "urgent"
+)
+
+func (e *TodoPriority) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = TodoPriority(s)
+	case string:
+		*e = TodoPriority(s)
+	default:
+		return fmt.Errorf("unsupported scan type for TodoPriority: %T", src)
+	}
+	return nil
+}
+
+type NullTodoPriority struct {
+	TodoPriority TodoPriority `json:"todo_priority"`
+	Valid        bool         `json:"valid"` // Valid is true if TodoPriority is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullTodoPriority) Scan(value interface{}) error {
+	if value == nil {
+		ns.TodoPriority, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.TodoPriority.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullTodoPriority) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.TodoPriority), nil
+}
+
+type UserRole string
+
+const (
+	UserRoleAdmin  UserRole = "admin"
+	UserRoleMember UserRole = "member"
+)
+
+func (e *UserRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = UserRole(s)
+	case string:
+		*e = UserRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for UserRole: %T", src)
+	}
+	return nil
+}
+
+type NullUserRole struct {
+	UserRole UserRole `json:"user_role"`
+	Valid    bool     `json:"valid"` // Valid is true if UserRole is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullUserRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.UserRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.UserRole.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullUserRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.UserRole), nil
+}
+
+type ApiKey struct {
+	ID         int32            `json:"id"`
+	UserID     int32            `json:"user_id"`
+	Name       string           `json:"name"`
+	Prefix     string           `json:"prefix"`
+	KeyHash    string           `json:"key_hash"`
+	CreatedAt  time.Time        `json:"created_at"`
+	LastUsedAt pgtype.Timestamp `json:"last_used_at"`
+	RevokedAt  pgtype.Timestamp `json:"revoked_at"`
+}
+
+type AuditEvent struct {
+	ID          int32       `json:"id"`
+	ActorID     pgtype.Int4 `json:"actor_id"`
+	Action      AuditAction `json:"action"`
+	EntityType  string      `json:"entity_type"`
+	EntityID    int32       `json:"entity_id"`
+	BeforeState []byte      `json:"before_state"`
+	AfterState  []byte      `json:"after_state"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+type Label struct {
+	ID        int32  `json:"id"`
+	CreatorID *int32 `json:"creator_id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+}
+
+type LockoutEvent struct {
+	ID          int32            `json:"id"`
+	Action      LockoutAction    `json:"action"`
+	Key         string           `json:"key"`
+	UserID      pgtype.Int4      `json:"user_id"`
+	ActorID     pgtype.Int4      `json:"actor_id"`
+	Ip          pgtype.Text      `json:"ip"`
+	Failures    int32            `json:"failures"`
+	LockedUntil pgtype.Timestamp `json:"locked_until"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+type LoginThrottle struct {
+	Key           string           `json:"key"`
+	Failures      int32            `json:"failures"`
+	LockedUntil   pgtype.Timestamp `json:"locked_until"`
+	LastFailureAt time.Time        `json:"last_failure_at"`
+}
+
+type OidcAuthRequest struct {
+	State        string    `json:"state"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type OrphanedBlob struct {
+	StorageKey string    `json:"storage_key"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	ID        int32            `json:"id"`
+	UserID    int32            `json:"user_id"`
+	TokenHash string           `json:"token_hash"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	UsedAt    pgtype.Timestamp `json:"used_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RefreshToken struct {
+	ID        int32            `json:"id"`
+	UserID    int32            `json:"user_id"`
+	FamilyID  string           `json:"family_id"`
+	TokenHash string           `json:"token_hash"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	RevokedAt pgtype.Timestamp `json:"revoked_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type Session struct {
+	ID        int32     `json:"id"`
+	UserID    int32     `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	CsrfToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Todo struct {
+	ID          int32        `json:"id"`
+	CreatorID   int32        `json:"creator_id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Completed   bool         `json:"completed"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	DueAt       *time.Time   `json:"due_at"`
+	Priority    TodoPriority `json:"priority"`
+	ParentID    *int32       `json:"parent_id"`
+}
+
+type TodoAttachment struct {
 	ID          int32     `json:"id"`
-	CreatorID   int32     `json:"creator_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed"`
+	TodoID      int32     `json:"todo_id"`
+	UploaderID  int32     `json:"uploader_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	StorageKey  string    `json:"storage_key"`
 	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TodoComment struct {
+	ID        int32            `json:"id"`
+	TodoID    int32            `json:"todo_id"`
+	AuthorID  int32            `json:"author_id"`
+	Body      string           `json:"body"`
+	CreatedAt time.Time        `json:"created_at"`
+	EditedAt  pgtype.Timestamp `json:"edited_at"`
+	DeletedAt pgtype.Timestamp `json:"deleted_at"`
+}
+
+type TodoLabel struct {
+	TodoID  int32 `json:"todo_id"`
+	LabelID int32 `json:"label_id"`
+}
+
+type TodoReminder struct {
+	ID            int32            `json:"id"`
+	TodoID        int32            `json:"todo_id"`
+	OffsetSeconds int32            `json:"offset_seconds"`
+	SentAt        pgtype.Timestamp `json:"sent_at"`
 }
 
 type TodoUser struct {
@@ -24,8 +333,34 @@ type TodoUser struct {
 }
 
 type User struct {
-	ID       int32  `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	ID              int32            `json:"id"`
+	Username        string           `json:"username"`
+	Email           string           `json:"email"`
+	PasswordHash    string           `json:"password_hash"`
+	Role            UserRole         `json:"role"`
+	EmailVerifiedAt pgtype.Timestamp `json:"email_verified_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+type UserIdentity struct {
+	ID        int32     `json:"id"`
+	UserID    int32     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserRecoveryCode struct {
+	ID       int32            `json:"id"`
+	UserID   int32            `json:"user_id"`
+	CodeHash string           `json:"code_hash"`
+	UsedAt   pgtype.Timestamp `json:"used_at"`
+}
+
+type UserTotp struct {
+	UserID       int32            `json:"user_id"`
+	Secret       []byte           `json:"secret"`
+	EnabledAt    pgtype.Timestamp `json:"enabled_at"`
+	LastUsedStep int64            `json:"last_used_step"`
+	CreatedAt    time.Time        `json:"created_at"`
 }
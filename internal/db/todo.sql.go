@@ -7,11 +7,15 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const assignUserToTodo = `-- name: AssignUserToTodo :execrows
 INSERT INTO todo_user (todo_id, user_id)
-VALUES ($1, $2)
+SELECT $1::int, "user".id FROM "user"
+WHERE "user".id = $2 AND "user".email_verified_at IS NOT NULL
 `
 
 type AssignUserToTodoParams struct {
@@ -27,20 +31,58 @@ func (q *Queries) AssignUserToTodo(ctx context.Context, arg AssignUserToTodoPara
 	return result.RowsAffected(), nil
 }
 
+const completeTodoIfSubtasksDone = `-- name: CompleteTodoIfSubtasksDone :one
+UPDATE todo
+SET completed = true, updated_at = CURRENT_TIMESTAMP
+WHERE todo.id = $1 AND NOT todo.completed AND NOT EXISTS (
+    SELECT 1 FROM todo AS subtask
+    WHERE subtask.parent_id = todo.id AND NOT subtask.completed
+)
+RETURNING id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id
+`
+
+func (q *Queries) CompleteTodoIfSubtasksDone(ctx context.Context, id int32) (Todo, error) {
+	row := q.db.QueryRow(ctx, completeTodoIfSubtasksDone, id)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.CreatorID,
+		&i.Title,
+		&i.Description,
+		&i.Completed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ParentID,
+	)
+	return i, err
+}
+
 const createTodo = `-- name: CreateTodo :one
-INSERT INTO todo (title, description, creator_id)
-VALUES ($1, $2, $3)
-RETURNING id, creator_id, title, description, completed, created_at, updated_at
+INSERT INTO todo (title, description, creator_id, due_at, priority, parent_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id
 `
 
 type CreateTodoParams struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	CreatorID   int32  `json:"creator_id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	CreatorID   int32        `json:"creator_id"`
+	DueAt       *time.Time   `json:"due_at"`
+	Priority    TodoPriority `json:"priority"`
+	ParentID    *int32       `json:"parent_id"`
 }
 
 func (q *Queries) CreateTodo(ctx context.Context, arg CreateTodoParams) (Todo, error) {
-	row := q.db.QueryRow(ctx, createTodo, arg.Title, arg.Description, arg.CreatorID)
+	row := q.db.QueryRow(ctx, createTodo,
+		arg.Title,
+		arg.Description,
+		arg.CreatorID,
+		arg.DueAt,
+		arg.Priority,
+		arg.ParentID,
+	)
 	var i Todo
 	err := row.Scan(
 		&i.ID,
@@ -50,6 +92,9 @@ func (q *Queries) CreateTodo(ctx context.Context, arg CreateTodoParams) (Todo, e
 		&i.Completed,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ParentID,
 	)
 	return i, err
 }
@@ -68,13 +113,36 @@ func (q *Queries) DeleteTodo(ctx context.Context, id int32) (int64, error) {
 }
 
 const getAllTodosOfUser = `-- name: GetAllTodosOfUser :many
-SELECT todo.id, todo.creator_id, todo.title, todo.description, todo.completed, todo.created_at, todo.updated_at FROM todo
+SELECT todo.id, todo.creator_id, todo.title, todo.description, todo.completed, todo.created_at, todo.updated_at, todo.due_at, todo.priority, todo.parent_id FROM todo
 LEFT JOIN todo_user ON todo.id = todo_user.todo_id
-WHERE todo_user.user_id = $1 OR todo.creator_id = $1
+WHERE (todo_user.user_id = $1 OR todo.creator_id = $1)
+    AND ($2::bool IS NULL OR COALESCE(todo.due_at < CURRENT_TIMESTAMP AND NOT todo.completed, false) = $2::bool)
+    AND ($3::todo_priority IS NULL OR todo.priority = $3::todo_priority)
+    AND (cardinality($4::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY($4::int[])
+    ) >= CASE WHEN $5::bool THEN cardinality($4::int[]) ELSE 1 END)
+ORDER BY CASE WHEN $6::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC
 `
 
-func (q *Queries) GetAllTodosOfUser(ctx context.Context, userID int32) ([]Todo, error) {
-	rows, err := q.db.Query(ctx, getAllTodosOfUser, userID)
+type GetAllTodosOfUserParams struct {
+	UserID         int32            `json:"user_id"`
+	Overdue        pgtype.Bool      `json:"overdue"`
+	Priority       NullTodoPriority `json:"priority"`
+	LabelIds       []int32          `json:"label_ids"`
+	MatchAllLabels bool             `json:"match_all_labels"`
+	Sort           string           `json:"sort"`
+}
+
+func (q *Queries) GetAllTodosOfUser(ctx context.Context, arg GetAllTodosOfUserParams) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, getAllTodosOfUser,
+		arg.UserID,
+		arg.Overdue,
+		arg.Priority,
+		arg.LabelIds,
+		arg.MatchAllLabels,
+		arg.Sort,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +158,9 @@ func (q *Queries) GetAllTodosOfUser(ctx context.Context, userID int32) ([]Todo,
 			&i.Completed,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ParentID,
 		); err != nil {
 			return nil, err
 		}
@@ -102,13 +173,36 @@ func (q *Queries) GetAllTodosOfUser(ctx context.Context, userID int32) ([]Todo,
 }
 
 const getAssignedTodosOfUser = `-- name: GetAssignedTodosOfUser :many
-SELECT todo.id, todo.creator_id, todo.title, todo.description, todo.completed, todo.created_at, todo.updated_at FROM todo
+SELECT todo.id, todo.creator_id, todo.title, todo.description, todo.completed, todo.created_at, todo.updated_at, todo.due_at, todo.priority, todo.parent_id FROM todo
 JOIN todo_user ON todo.id = todo_user.todo_id
 WHERE todo_user.user_id = $1
+    AND ($2::bool IS NULL OR COALESCE(todo.due_at < CURRENT_TIMESTAMP AND NOT todo.completed, false) = $2::bool)
+    AND ($3::todo_priority IS NULL OR todo.priority = $3::todo_priority)
+    AND (cardinality($4::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY($4::int[])
+    ) >= CASE WHEN $5::bool THEN cardinality($4::int[]) ELSE 1 END)
+ORDER BY CASE WHEN $6::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC
 `
 
-func (q *Queries) GetAssignedTodosOfUser(ctx context.Context, userID int32) ([]Todo, error) {
-	rows, err := q.db.Query(ctx, getAssignedTodosOfUser, userID)
+type GetAssignedTodosOfUserParams struct {
+	UserID         int32            `json:"user_id"`
+	Overdue        pgtype.Bool      `json:"overdue"`
+	Priority       NullTodoPriority `json:"priority"`
+	LabelIds       []int32          `json:"label_ids"`
+	MatchAllLabels bool             `json:"match_all_labels"`
+	Sort           string           `json:"sort"`
+}
+
+func (q *Queries) GetAssignedTodosOfUser(ctx context.Context, arg GetAssignedTodosOfUserParams) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, getAssignedTodosOfUser,
+		arg.UserID,
+		arg.Overdue,
+		arg.Priority,
+		arg.LabelIds,
+		arg.MatchAllLabels,
+		arg.Sort,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +218,9 @@ func (q *Queries) GetAssignedTodosOfUser(ctx context.Context, userID int32) ([]T
 			&i.Completed,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ParentID,
 		); err != nil {
 			return nil, err
 		}
@@ -136,12 +233,458 @@ func (q *Queries) GetAssignedTodosOfUser(ctx context.Context, userID int32) ([]T
 }
 
 const getCreatedTodosOfUser = `-- name: GetCreatedTodosOfUser :many
-SELECT id, creator_id, title, description, completed, created_at, updated_at FROM todo
+SELECT id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id FROM todo
 WHERE todo.creator_id = $1
+    AND ($2::bool IS NULL OR COALESCE(todo.due_at < CURRENT_TIMESTAMP AND NOT todo.completed, false) = $2::bool)
+    AND ($3::todo_priority IS NULL OR todo.priority = $3::todo_priority)
+    AND (cardinality($4::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY($4::int[])
+    ) >= CASE WHEN $5::bool THEN cardinality($4::int[]) ELSE 1 END)
+ORDER BY CASE WHEN $6::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC
+`
+
+type GetCreatedTodosOfUserParams struct {
+	CreatorID      int32            `json:"creator_id"`
+	Overdue        pgtype.Bool      `json:"overdue"`
+	Priority       NullTodoPriority `json:"priority"`
+	LabelIds       []int32          `json:"label_ids"`
+	MatchAllLabels bool             `json:"match_all_labels"`
+	Sort           string           `json:"sort"`
+}
+
+func (q *Queries) GetCreatedTodosOfUser(ctx context.Context, arg GetCreatedTodosOfUserParams) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, getCreatedTodosOfUser,
+		arg.CreatorID,
+		arg.Overdue,
+		arg.Priority,
+		arg.LabelIds,
+		arg.MatchAllLabels,
+		arg.Sort,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Todo{}
+	for rows.Next() {
+		var i Todo
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatorID,
+			&i.Title,
+			&i.Description,
+			&i.Completed,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ParentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTodo = `-- name: GetTodo :one
+SELECT id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id FROM todo
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetTodo(ctx context.Context, id int32) (Todo, error) {
+	row := q.db.QueryRow(ctx, getTodo, id)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.CreatorID,
+		&i.Title,
+		&i.Description,
+		&i.Completed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ParentID,
+	)
+	return i, err
+}
+
+const getTodoAccess = `-- name: GetTodoAccess :one
+WITH RECURSIVE ancestor AS (
+    SELECT parent.id, parent.parent_id, parent.creator_id FROM todo
+    JOIN todo AS parent ON parent.id = todo.parent_id
+    WHERE todo.id = $1
+    UNION ALL
+    SELECT parent.id, parent.parent_id, parent.creator_id FROM todo AS parent
+    JOIN ancestor ON parent.id = ancestor.parent_id
+)
+SELECT todo.creator_id, EXISTS (
+    SELECT 1 FROM todo_user
+    WHERE todo_user.todo_id = todo.id AND todo_user.user_id = $2
+) AS assigned, EXISTS (
+    SELECT 1 FROM ancestor
+    WHERE ancestor.creator_id = $2 OR EXISTS (
+        SELECT 1 FROM todo_user
+        WHERE todo_user.todo_id = ancestor.id AND todo_user.user_id = $2
+    )
+) AS inherited
+FROM todo
+WHERE todo.id = $1
+`
+
+type GetTodoAccessParams struct {
+	ID     int32 `json:"id"`
+	UserID int32 `json:"user_id"`
+}
+
+type GetTodoAccessRow struct {
+	CreatorID int32 `json:"creator_id"`
+	Assigned  bool  `json:"assigned"`
+	Inherited bool  `json:"inherited"`
+}
+
+func (q *Queries) GetTodoAccess(ctx context.Context, arg GetTodoAccessParams) (GetTodoAccessRow, error) {
+	row := q.db.QueryRow(ctx, getTodoAccess, arg.ID, arg.UserID)
+	var i GetTodoAccessRow
+	err := row.Scan(&i.CreatorID, &i.Assigned, &i.Inherited)
+	return i, err
+}
+
+const getTodoForUpdate = `-- name: GetTodoForUpdate :one
+SELECT id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id FROM todo
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetTodoForUpdate(ctx context.Context, id int32) (Todo, error) {
+	row := q.db.QueryRow(ctx, getTodoForUpdate, id)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.CreatorID,
+		&i.Title,
+		&i.Description,
+		&i.Completed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ParentID,
+	)
+	return i, err
+}
+
+const getTodoTree = `-- name: GetTodoTree :many
+WITH RECURSIVE tree AS (
+    SELECT todo.id, 0 AS depth FROM todo
+    WHERE todo.id = $1
+    UNION ALL
+    SELECT todo.id, tree.depth + 1 FROM todo
+    JOIN tree ON todo.parent_id = tree.id
+)
+SELECT todo.id, todo.creator_id, todo.title, todo.description, todo.completed, todo.created_at, todo.updated_at, todo.due_at, todo.priority, todo.parent_id, tree.depth::int AS depth FROM tree
+JOIN todo ON todo.id = tree.id
+ORDER BY tree.depth, todo.created_at
+`
+
+type GetTodoTreeRow struct {
+	Todo  Todo  `json:"todo"`
+	Depth int32 `json:"depth"`
+}
+
+func (q *Queries) GetTodoTree(ctx context.Context, id int32) ([]GetTodoTreeRow, error) {
+	rows, err := q.db.Query(ctx, getTodoTree, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetTodoTreeRow{}
+	for rows.Next() {
+		var i GetTodoTreeRow
+		if err := rows.Scan(
+			&i.Todo.ID,
+			&i.Todo.CreatorID,
+			&i.Todo.Title,
+			&i.Todo.Description,
+			&i.Todo.Completed,
+			&i.Todo.CreatedAt,
+			&i.Todo.UpdatedAt,
+			&i.Todo.DueAt,
+			&i.Todo.Priority,
+			&i.Todo.ParentID,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTodoWithUsers = `-- name: GetTodoWithUsers :one
+SELECT todo.id, todo.creator_id, todo.title, todo.description, todo.completed, todo.created_at, todo.updated_at, todo.due_at, todo.priority, todo.parent_id, creator.id, creator.username, creator.email, creator.password_hash, creator.role, creator.email_verified_at, creator.updated_at, COALESCE((
+    SELECT json_agg(json_build_object(
+        'id', assignee.id,
+        'username', assignee.username,
+        'email', assignee.email,
+        'email_verified', assignee.email_verified_at IS NOT NULL,
+        'role', assignee.role
+    ) ORDER BY assignee.id)
+    FROM todo_user
+    JOIN "user" AS assignee ON todo_user.user_id = assignee.id
+    WHERE todo_user.todo_id = todo.id
+), '[]')::json AS assignees, COALESCE((
+    SELECT array_agg(todo_reminder.offset_seconds ORDER BY todo_reminder.offset_seconds)
+    FROM todo_reminder
+    WHERE todo_reminder.todo_id = todo.id
+), '{}')::int[] AS reminders, COALESCE((
+    SELECT json_agg(label ORDER BY lower(label.name))
+    FROM todo_label
+    JOIN label ON todo_label.label_id = label.id
+    WHERE todo_label.todo_id = todo.id
+), '[]')::json AS labels, (
+    SELECT count(*) FROM todo AS subtask
+    WHERE subtask.parent_id = todo.id
+) AS subtask_count, (
+    SELECT count(*) FROM todo AS subtask
+    WHERE subtask.parent_id = todo.id AND subtask.completed
+) AS completed_subtask_count
+FROM todo
+JOIN "user" AS creator ON todo.creator_id = creator.id
+WHERE todo.id = $1
+`
+
+type GetTodoWithUsersRow struct {
+	Todo                  Todo    `json:"todo"`
+	User                  User    `json:"user"`
+	Assignees             []byte  `json:"assignees"`
+	Reminders             []int32 `json:"reminders"`
+	Labels                []byte  `json:"labels"`
+	SubtaskCount          int64   `json:"subtask_count"`
+	CompletedSubtaskCount int64   `json:"completed_subtask_count"`
+}
+
+func (q *Queries) GetTodoWithUsers(ctx context.Context, id int32) (GetTodoWithUsersRow, error) {
+	row := q.db.QueryRow(ctx, getTodoWithUsers, id)
+	var i GetTodoWithUsersRow
+	err := row.Scan(
+		&i.Todo.ID,
+		&i.Todo.CreatorID,
+		&i.Todo.Title,
+		&i.Todo.Description,
+		&i.Todo.Completed,
+		&i.Todo.CreatedAt,
+		&i.Todo.UpdatedAt,
+		&i.Todo.DueAt,
+		&i.Todo.Priority,
+		&i.Todo.ParentID,
+		&i.User.ID,
+		&i.User.Username,
+		&i.User.Email,
+		&i.User.PasswordHash,
+		&i.User.Role,
+		&i.User.EmailVerifiedAt,
+		&i.User.UpdatedAt,
+		&i.Assignees,
+		&i.Reminders,
+		&i.Labels,
+		&i.SubtaskCount,
+		&i.CompletedSubtaskCount,
+	)
+	return i, err
+}
+
+const listSubtasks = `-- name: ListSubtasks :many
+SELECT id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id FROM todo
+WHERE parent_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListSubtasks(ctx context.Context, parentID *int32) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, listSubtasks, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Todo{}
+	for rows.Next() {
+		var i Todo
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatorID,
+			&i.Title,
+			&i.Description,
+			&i.Completed,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ParentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodoAncestorIDs = `-- name: ListTodoAncestorIDs :many
+WITH RECURSIVE ancestor AS (
+    SELECT todo.id, todo.parent_id, 0 AS depth FROM todo
+    WHERE todo.id = $1
+    UNION ALL
+    SELECT todo.id, todo.parent_id, ancestor.depth + 1 FROM todo
+    JOIN ancestor ON todo.id = ancestor.parent_id
+)
+SELECT id FROM ancestor
+ORDER BY depth
+`
+
+func (q *Queries) ListTodoAncestorIDs(ctx context.Context, id int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listTodoAncestorIDs, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodoAssignees = `-- name: ListTodoAssignees :many
+SELECT "user".id, "user".username, "user".email, "user".password_hash, "user".role, "user".email_verified_at, "user".updated_at FROM "user"
+JOIN todo_user ON "user".id = todo_user.user_id
+WHERE todo_user.todo_id = $1
+ORDER BY "user".id
 `
 
-func (q *Queries) GetCreatedTodosOfUser(ctx context.Context, creatorID int32) ([]Todo, error) {
-	rows, err := q.db.Query(ctx, getCreatedTodosOfUser, creatorID)
+func (q *Queries) ListTodoAssignees(ctx context.Context, todoID int32) ([]User, error) {
+	rows, err := q.db.Query(ctx, listTodoAssignees, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Role,
+			&i.EmailVerifiedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodoAssignmentsForDeletion = `-- name: ListTodoAssignmentsForDeletion :many
+SELECT todo_id, user_id FROM todo_user
+WHERE todo_id = ANY($1::int[]) OR user_id = $2::int
+ORDER BY todo_id, user_id
+`
+
+type ListTodoAssignmentsForDeletionParams struct {
+	TodoIds []int32     `json:"todo_ids"`
+	UserID  pgtype.Int4 `json:"user_id"`
+}
+
+func (q *Queries) ListTodoAssignmentsForDeletion(ctx context.Context, arg ListTodoAssignmentsForDeletionParams) ([]TodoUser, error) {
+	rows, err := q.db.Query(ctx, listTodoAssignmentsForDeletion, arg.TodoIds, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []TodoUser{}
+	for rows.Next() {
+		var i TodoUser
+		if err := rows.Scan(&i.TodoID, &i.UserID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodoRootIDs = `-- name: ListTodoRootIDs :many
+WITH RECURSIVE ancestor AS (
+    SELECT todo.id, todo.parent_id FROM todo
+    WHERE todo.id = ANY($1::int[])
+    UNION
+    SELECT todo.id, todo.parent_id FROM todo
+    JOIN ancestor ON todo.id = ancestor.parent_id
+)
+SELECT id FROM ancestor
+WHERE parent_id IS NULL
+ORDER BY id
+`
+
+func (q *Queries) ListTodoRootIDs(ctx context.Context, ids []int32) ([]int32, error) {
+	rows, err := q.db.Query(ctx, listTodoRootIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []int32{}
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTodoTreesOfCreator = `-- name: ListTodoTreesOfCreator :many
+WITH RECURSIVE tree AS (
+    SELECT todo.id FROM todo
+    WHERE todo.creator_id = $1
+    UNION
+    SELECT todo.id FROM todo
+    JOIN tree ON todo.parent_id = tree.id
+)
+SELECT id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id FROM todo
+WHERE id IN (SELECT id FROM tree)
+ORDER BY id
+`
+
+func (q *Queries) ListTodoTreesOfCreator(ctx context.Context, creatorID int32) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, listTodoTreesOfCreator, creatorID)
 	if err != nil {
 		return nil, err
 	}
@@ -157,6 +700,9 @@ func (q *Queries) GetCreatedTodosOfUser(ctx context.Context, creatorID int32) ([
 			&i.Completed,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ParentID,
 		); err != nil {
 			return nil, err
 		}
@@ -169,12 +715,80 @@ func (q *Queries) GetCreatedTodosOfUser(ctx context.Context, creatorID int32) ([
 }
 
 const listTodos = `-- name: ListTodos :many
-SELECT id, creator_id, title, description, completed, created_at, updated_at FROM todo
-ORDER BY created_at DESC
+SELECT id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id FROM todo
+WHERE ($1::todo_priority IS NULL OR todo.priority = $1::todo_priority)
+    AND (cardinality($2::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY($2::int[])
+    ) >= CASE WHEN $3::bool THEN cardinality($2::int[]) ELSE 1 END)
+ORDER BY CASE WHEN $4::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC
+`
+
+type ListTodosParams struct {
+	Priority       NullTodoPriority `json:"priority"`
+	LabelIds       []int32          `json:"label_ids"`
+	MatchAllLabels bool             `json:"match_all_labels"`
+	Sort           string           `json:"sort"`
+}
+
+func (q *Queries) ListTodos(ctx context.Context, arg ListTodosParams) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, listTodos,
+		arg.Priority,
+		arg.LabelIds,
+		arg.MatchAllLabels,
+		arg.Sort,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Todo{}
+	for rows.Next() {
+		var i Todo
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatorID,
+			&i.Title,
+			&i.Description,
+			&i.Completed,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ParentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockTodoTrees = `-- name: LockTodoTrees :exec
+SELECT pg_advisory_xact_lock(hashtext('todo_tree'), root.id)
+FROM (
+    SELECT unnest($1::int[]) AS id
+    ORDER BY id
+) AS root
+`
+
+func (q *Queries) LockTodoTrees(ctx context.Context, rootIds []int32) error {
+	_, err := q.db.Exec(ctx, lockTodoTrees, rootIds)
+	return err
+}
+
+const lockTodos = `-- name: LockTodos :many
+SELECT id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id FROM todo
+WHERE id = ANY($1::int[])
+ORDER BY id
+FOR UPDATE
 `
 
-func (q *Queries) ListTodos(ctx context.Context) ([]Todo, error) {
-	rows, err := q.db.Query(ctx, listTodos)
+func (q *Queries) LockTodos(ctx context.Context, ids []int32) ([]Todo, error) {
+	rows, err := q.db.Query(ctx, lockTodos, ids)
 	if err != nil {
 		return nil, err
 	}
@@ -190,6 +804,9 @@ func (q *Queries) ListTodos(ctx context.Context) ([]Todo, error) {
 			&i.Completed,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.DueAt,
+			&i.Priority,
+			&i.ParentID,
 		); err != nil {
 			return nil, err
 		}
@@ -201,18 +818,68 @@ func (q *Queries) ListTodos(ctx context.Context) ([]Todo, error) {
 	return items, nil
 }
 
+const setTodoParent = `-- name: SetTodoParent :one
+UPDATE todo
+SET parent_id = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+RETURNING id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id
+`
+
+type SetTodoParentParams struct {
+	ParentID *int32 `json:"parent_id"`
+	ID       int32  `json:"id"`
+}
+
+func (q *Queries) SetTodoParent(ctx context.Context, arg SetTodoParentParams) (Todo, error) {
+	row := q.db.QueryRow(ctx, setTodoParent, arg.ParentID, arg.ID)
+	var i Todo
+	err := row.Scan(
+		&i.ID,
+		&i.CreatorID,
+		&i.Title,
+		&i.Description,
+		&i.Completed,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ParentID,
+	)
+	return i, err
+}
+
+const unassignUserFromTodo = `-- name: UnassignUserFromTodo :execrows
+DELETE FROM todo_user
+WHERE todo_id = $1 AND user_id = $2
+`
+
+type UnassignUserFromTodoParams struct {
+	TodoID int32 `json:"todo_id"`
+	UserID int32 `json:"user_id"`
+}
+
+func (q *Queries) UnassignUserFromTodo(ctx context.Context, arg UnassignUserFromTodoParams) (int64, error) {
+	result, err := q.db.Exec(ctx, unassignUserFromTodo, arg.TodoID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateTodo = `-- name: UpdateTodo :one
 UPDATE todo
-SET title = $1, description = $2, completed = $3
-WHERE id = $4
-RETURNING id, creator_id, title, description, completed, created_at, updated_at
+SET title = $1, description = $2, completed = $3, due_at = $4, priority = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $6
+RETURNING id, creator_id, title, description, completed, created_at, updated_at, due_at, priority, parent_id
 `
 
 type UpdateTodoParams struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   bool   `json:"completed"`
-	ID          int32  `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Completed   bool         `json:"completed"`
+	DueAt       *time.Time   `json:"due_at"`
+	Priority    TodoPriority `json:"priority"`
+	ID          int32        `json:"id"`
 }
 
 func (q *Queries) UpdateTodo(ctx context.Context, arg UpdateTodoParams) (Todo, error) {
@@ -220,6 +887,8 @@ func (q *Queries) UpdateTodo(ctx context.Context, arg UpdateTodoParams) (Todo, e
 		arg.Title,
 		arg.Description,
 		arg.Completed,
+		arg.DueAt,
+		arg.Priority,
 		arg.ID,
 	)
 	var i Todo
@@ -231,6 +900,9 @@ func (q *Queries) UpdateTodo(ctx context.Context, arg UpdateTodoParams) (Todo, e
 		&i.Completed,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DueAt,
+		&i.Priority,
+		&i.ParentID,
 	)
 	return i, err
 }
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// @Summary Create an API key
+// @Description Create a new API key for the user. The key is only part of
+// @Description this response and can't be retrieved later on.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param apiKey body ApiKeyCreateRequest true "API key data"
+// @Success 201 {object} ApiKeyCreatedResponse "Created API key"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/api-keys [post]
+func (u *UserHandler) createApiKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	apiKey := &ApiKeyCreateRequest{}
+
+	if !decodeAndValidate(w, r, apiKey) {
+		return
+	}
+
+	key, prefix, keyHash, err := auth.NewApiKey()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	params := db.CreateApiKeyParams{
+		UserID:  userID,
+		Name:    apiKey.Name,
+		Prefix:  prefix,
+		KeyHash: keyHash,
+	}
+	var dbApiKey db.ApiKey
+	err = withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		dbApiKey, err = q.CreateApiKey(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityApiKey, dbApiKey.ID, nil, newApiKeyResponse(dbApiKey))
+	})
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := ApiKeyCreatedResponse{
+		ApiKeyResponse: newApiKeyResponse(dbApiKey),
+		Key:            key,
+	}
+	writeJson(w, response, http.StatusCreated)
+}
+
+// @Summary Get all API keys of a user
+// @Description Get the list of all API keys of the user, including revoked ones.
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {array} ApiKeyResponse "List of API keys"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/api-keys [get]
+func (u *UserHandler) getApiKeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	apiKeys, err := u.queries.ListApiKeysOfUser(r.Context(), userID)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := make([]ApiKeyResponse, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		response[i] = newApiKeyResponse(apiKey)
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Revoke an API key
+// @Description Revoke an API key of the user. Revoked keys can't be used anymore.
+// @Tags User
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param keyId path int true "API key ID"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User or API key not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/api-keys/{keyId} [delete]
+func (u *UserHandler) revokeApiKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+	apiKeyID := r.Context().Value(apiKeyIDKey).(int32)
+
+	params := db.RevokeApiKeyParams{
+		ID:     apiKeyID,
+		UserID: userID,
+	}
+	err := withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		apiKey, err := q.RevokeApiKey(r.Context(), params)
+		if err != nil {
+			return err
+		}
+
+		before := newApiKeyResponse(apiKey)
+		before.RevokedAt = nil
+		return recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityApiKey, apiKey.ID, before, newApiKeyResponse(apiKey))
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeApiKeyNotFoundError(w, apiKeyID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
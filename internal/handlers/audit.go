@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// Entity types of audit events.
+const (
+	auditEntityUser       = "user"
+	auditEntityTodo       = "todo"
+	auditEntityApiKey     = "api_key"
+	auditEntityLabel      = "label"
+	auditEntityComment    = "comment"
+	auditEntityAttachment = "attachment"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 100
+)
+
+// auditAssignment is the snapshot of a user assignment to a todo.
+type auditAssignment struct {
+	UserID int32 `json:"user_id"`
+}
+
+// auditLabelAssignment is the snapshot of a label attached to a todo.
+type auditLabelAssignment struct {
+	LabelID int32 `json:"label_id"`
+}
+
+// auditCredential is the snapshot of a credential of a user, like its
+// password or TOTP secret. The secret itself is never recorded.
+type auditCredential struct {
+	Credential string `json:"credential"`
+}
+
+type AuditHandler struct {
+	*chi.Mux
+	queries *db.Queries
+}
+
+func NewAuditHandler(queries *db.Queries, authn *Authenticator, limiter *RateLimiter) *AuditHandler {
+	auditHandler := &AuditHandler{chi.NewRouter(), queries}
+
+	auditHandler.Use(authn.Middleware)
+	auditHandler.Use(limiter.Limit("audit"))
+	auditHandler.Use(authorize(isAdmin))
+
+	auditHandler.Get("/", auditHandler.getAuditEvents)
+	return auditHandler
+}
+
+// recordAudit stores an audit event for a mutation. q has to belong to the
+// transaction of the mutation, so that the event is only stored if the
+// mutation is. before and after are snapshots of the entity and are nil if it
+// didn't exist before or doesn't exist anymore.
+func recordAudit(ctx context.Context, q *db.Queries, action db.AuditAction, entityType string, entityID int32, before, after any) error {
+	var actorID pgtype.Int4
+	if userID, ok := ctx.Value(authUserIDKey).(int32); ok {
+		actorID = pgtype.Int4{Int32: userID, Valid: true}
+	}
+
+	beforeState, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+
+	afterState, err := auditSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	return q.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+		ActorID:     actorID,
+		Action:      action,
+		EntityType:  entityType,
+		EntityID:    entityID,
+		BeforeState: beforeState,
+		AfterState:  afterState,
+	})
+}
+
+func auditSnapshot(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// @Summary Get the audit log
+// @Description Get the audit events of mutating operations, newest first.
+// @Description Pass the next_cursor of a page as cursor to get the next page.
+// @Description Only administrators may do this.
+// @Tags Audit
+// @Produce json
+// @Security BearerAuth
+// @Param actor_id query int false "ID of the user that performed the operation"
+// @Param action query string false "Action" Enums(create, update, delete, assign, unassign)
+// @Param entity_type query string false "Type of the changed entity" Enums(user, todo, api_key, label, comment, attachment)
+// @Param entity_id query int false "ID of the changed entity"
+// @Param since query string false "Only events at or after this time (RFC 3339)"
+// @Param until query string false "Only events before this time (RFC 3339)"
+// @Param cursor query int false "Cursor of the page"
+// @Param limit query int false "Number of events per page (1-100, default 50)"
+// @Success 200 {object} AuditEventPageResponse "Page of audit events"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /audit [get]
+func (a *AuditHandler) getAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	params := db.ListAuditEventsParams{}
+	var ok bool
+
+	if params.ActorID, ok = parseInt4Query(w, query, "actor_id"); !ok {
+		return
+	}
+	if params.EntityID, ok = parseInt4Query(w, query, "entity_id"); !ok {
+		return
+	}
+	if params.Cursor, ok = parseInt4Query(w, query, "cursor"); !ok {
+		return
+	}
+	if params.Since, ok = parseTimestampQuery(w, query, "since"); !ok {
+		return
+	}
+	if params.Until, ok = parseTimestampQuery(w, query, "until"); !ok {
+		return
+	}
+
+	if action := query.Get("action"); action != "" {
+		switch db.AuditAction(action) {
+		case db.AuditActionCreate, db.AuditActionUpdate, db.AuditActionDelete, db.AuditActionAssign, db.AuditActionUnassign:
+			params.Action = db.NullAuditAction{AuditAction: db.AuditAction(action), Valid: true}
+		default:
+			writeInvalidQueryError(w, action, []string{"create", "update", "delete", "assign", "unassign"})
+			return
+		}
+	}
+
+	if entityType := query.Get("entity_type"); entityType != "" {
+		params.EntityType = pgtype.Text{String: entityType, Valid: true}
+	}
+
+	if params.PageSize, ok = parsePageSizeQuery(w, query, defaultAuditPageSize, maxAuditPageSize); !ok {
+		return
+	}
+
+	// One more event than requested tells whether there is another page.
+	pageSize := params.PageSize
+	params.PageSize++
+	events, err := a.queries.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := AuditEventPageResponse{Events: []AuditEventResponse{}}
+	if len(events) > int(pageSize) {
+		events = events[:pageSize]
+		response.NextCursor = &events[len(events)-1].ID
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, newAuditEventResponse(event))
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+func parseInt4Query(w http.ResponseWriter, query url.Values, name string) (pgtype.Int4, bool) {
+	value := query.Get(name)
+	if value == "" {
+		return pgtype.Int4{}, true
+	}
+
+	i, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		writeInvalidQueryParamError(w, name, value)
+		return pgtype.Int4{}, false
+	}
+	return pgtype.Int4{Int32: int32(i), Valid: true}, true
+}
+
+// parsePageSizeQuery parses the limit query parameter of a paginated list.
+func parsePageSizeQuery(w http.ResponseWriter, query url.Values, fallback int32, maxPageSize int32) (int32, bool) {
+	value := query.Get("limit")
+	if value == "" {
+		return fallback, true
+	}
+
+	pageSize, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || pageSize < 1 || pageSize > int64(maxPageSize) {
+		writeInvalidQueryParamError(w, "limit", value)
+		return 0, false
+	}
+	return int32(pageSize), true
+}
+
+func parseBoolQuery(w http.ResponseWriter, query url.Values, name string) (pgtype.Bool, bool) {
+	value := query.Get(name)
+	if value == "" {
+		return pgtype.Bool{}, true
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		writeInvalidQueryParamError(w, name, value)
+		return pgtype.Bool{}, false
+	}
+	return pgtype.Bool{Bool: b, Valid: true}, true
+}
+
+func parseTimestampQuery(w http.ResponseWriter, query url.Values, name string) (pgtype.Timestamp, bool) {
+	value := query.Get(name)
+	if value == "" {
+		return pgtype.Timestamp{}, true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		writeInvalidQueryParamError(w, name, value)
+		return pgtype.Timestamp{}, false
+	}
+	// The timestamps in the database are stored in UTC without a time zone.
+	return pgtype.Timestamp{Time: t.UTC(), Valid: true}, true
+}
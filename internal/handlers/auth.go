@@ -0,0 +1,537 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
+	"github.com/mderler/simple-go-backend/internal/mailer"
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+type AuthHandler struct {
+	*chi.Mux
+	conn      *pgxpool.Pool
+	queries   *db.Queries
+	passwords *auth.PasswordHasher
+	tokens    *auth.TokenManager
+	totp      *auth.TotpManager
+	mailer    mailer.Mailer
+	resets    PasswordResetConfig
+	guard     *LoginGuard
+	providers map[string]*auth.OidcProvider
+	sessions  SessionConfig
+}
+
+func NewAuthHandler(conn *pgxpool.Pool, queries *db.Queries, passwords *auth.PasswordHasher, tokens *auth.TokenManager, totp *auth.TotpManager, mailer mailer.Mailer, resets PasswordResetConfig, guard *LoginGuard, providers map[string]*auth.OidcProvider, sessions SessionConfig, authn *Authenticator, limiter *RateLimiter) *AuthHandler {
+	authHandler := &AuthHandler{chi.NewRouter(), conn, queries, passwords, tokens, totp, mailer, resets, guard, providers, sessions}
+
+	authHandler.Use(limiter.Limit("auth"))
+
+	authHandler.Post("/login", authHandler.login)
+	authHandler.Post("/login/2fa", authHandler.loginTwoFactor)
+	authHandler.Get("/oidc/{provider}/login", authHandler.oidcLogin)
+	authHandler.Get("/oidc/{provider}/callback", authHandler.oidcCallback)
+	authHandler.Post("/session", authHandler.createSession)
+	authHandler.Post("/session/2fa", authHandler.createSessionTwoFactor)
+	authHandler.With(authn.Middleware).Get("/session", authHandler.getSession)
+	authHandler.With(authn.Session, csrfProtect).Delete("/session", authHandler.deleteSession)
+	authHandler.Post("/refresh", authHandler.refresh)
+	authHandler.Post("/logout", authHandler.logout)
+	authHandler.Post("/password-reset", authHandler.requestPasswordReset)
+	authHandler.Post("/password-reset/confirm", authHandler.confirmPasswordReset)
+	authHandler.Post("/verify-email", authHandler.verifyEmail)
+	authHandler.With(authn.Middleware, csrfProtect).Post("/logout-all", authHandler.logoutAll)
+	return authHandler
+}
+
+// @Summary Log in
+// @Description Check the credentials of a user and issue an access token
+// @Description together with a refresh token that starts a new token family.
+// @Description Users with two-factor authentication get an MFA token instead,
+// @Description which has to be exchanged at /auth/login/2fa. Repeated failures
+// @Description lock the account and the IP address for an increasing time.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginRequest true "User credentials"
+// @Success 200 {object} TokenResponse "Issued tokens"
+// @Success 202 {object} MfaChallengeResponse "Second factor required"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Invalid credentials"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests or login locked"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/login [post]
+func (a *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.checkPassword(w, r)
+	if !ok {
+		return
+	}
+
+	a.completeLogin(w, r, user, a.respondWithTokens)
+}
+
+// @Summary Complete a login with a second factor
+// @Description Exchange the MFA token of a login together with a TOTP code or
+// @Description a recovery code for an access and a refresh token.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginTwoFactorRequest true "MFA token and code"
+// @Success 200 {object} TokenResponse "Issued tokens"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Invalid credentials"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests or login locked"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/login/2fa [post]
+func (a *AuthHandler) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.checkSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	a.respondWithTokens(w, r, user)
+}
+
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access and refresh token.
+// @Description Every refresh token can only be used once. Presenting one that
+// @Description was already used revokes every token of its family.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param token body RefreshRequest true "Refresh token"
+// @Success 200 {object} TokenResponse "Issued tokens"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Invalid refresh token"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/refresh [post]
+func (a *AuthHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	request := &RefreshRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	var response TokenResponse
+	var reused bool
+	err := withTx(r.Context(), a.conn, a.queries, func(q *db.Queries) error {
+		current, err := q.GetRefreshTokenForUpdate(r.Context(), auth.HashOpaqueToken(request.RefreshToken))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+
+		if current.RevokedAt.Valid {
+			reused = true
+			return q.RevokeRefreshTokenFamily(r.Context(), current.FamilyID)
+		}
+		if current.Expired {
+			return errInvalidRefreshToken
+		}
+
+		if err := q.RevokeRefreshToken(r.Context(), current.ID); err != nil {
+			return err
+		}
+
+		user, err := q.GetUser(r.Context(), current.UserID)
+		if err != nil {
+			return err
+		}
+
+		response, err = a.issueTokens(r.Context(), q, user, current.FamilyID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidRefreshToken) {
+			writeUnauthorizedError(w, "The refresh token is invalid or expired")
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+	if reused {
+		log.Println("Refresh token reuse detected, revoked its token family")
+		writeUnauthorizedError(w, "The refresh token is invalid or expired")
+		return
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Log out
+// @Description Revoke the refresh token and every other token of its family.
+// @Description Access tokens stay valid until they expire.
+// @Tags Auth
+// @Accept json
+// @Param token body RefreshRequest true "Refresh token"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/logout [post]
+func (a *AuthHandler) logout(w http.ResponseWriter, r *http.Request) {
+	request := &RefreshRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	err := withTx(r.Context(), a.conn, a.queries, func(q *db.Queries) error {
+		current, err := q.GetRefreshTokenForUpdate(r.Context(), auth.HashOpaqueToken(request.RefreshToken))
+		if err != nil {
+			return err
+		}
+		return q.RevokeRefreshTokenFamily(r.Context(), current.FamilyID)
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Log out of all devices
+// @Description Revoke every refresh token and every session of the
+// @Description authenticated user. Access tokens stay valid until they expire.
+// @Tags Auth
+// @Security BearerAuth
+// @Success 204 "No content"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/logout-all [post]
+func (a *AuthHandler) logoutAll(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(authUserIDKey).(int32)
+
+	err := withTx(r.Context(), a.conn, a.queries, func(q *db.Queries) error {
+		if err := q.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+			return err
+		}
+		return q.DeleteUserSessions(r.Context(), userID)
+	})
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Verify an email address
+// @Description Confirm the email address of a user with the token of a
+// @Description verification link. Using a token again has no further effect.
+// @Tags Auth
+// @Accept json
+// @Param token body VerifyEmailRequest true "Verification token"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Invalid verification token"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/verify-email [post]
+func (a *AuthHandler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	request := &VerifyEmailRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	userID, email, err := a.tokens.ParseVerificationToken(request.Token)
+	if err != nil {
+		writeInvalidVerificationTokenError(w)
+		return
+	}
+
+	params := db.VerifyUserEmailParams{
+		ID:    userID,
+		Email: email,
+	}
+	err = withTx(r.Context(), a.conn, a.queries, func(q *db.Queries) error {
+		before, err := q.GetUser(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := q.VerifyUserEmail(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return pgx.ErrNoRows
+		}
+
+		user, err := q.GetUser(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityUser, userID, newUserResponse(before), newUserResponse(user))
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeInvalidVerificationTokenError(w)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPassword decodes the login request and checks the credentials. On
+// failure the error is already written and false is returned.
+func (a *AuthHandler) checkPassword(w http.ResponseWriter, r *http.Request) (db.User, bool) {
+	credentials := &LoginRequest{}
+
+	if !decodeAndValidate(w, r, credentials) {
+		return db.User{}, false
+	}
+
+	// New usernames can't contain an @, so anything with one is looked up as
+	// an email first. Usernames from before that rule still work.
+	var user db.User
+	var err error
+	if strings.Contains(credentials.Username, "@") {
+		user, err = a.queries.GetUserByEmail(r.Context(), credentials.Username)
+	}
+	if !strings.Contains(credentials.Username, "@") || errors.Is(err, pgx.ErrNoRows) {
+		user, err = a.queries.GetUserByUsername(r.Context(), credentials.Username)
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeInternalServerError(w, err)
+		return db.User{}, false
+	}
+	found := err == nil
+
+	attempt := nameAttempt(r, credentials.Username)
+	if found {
+		attempt = userAttempt(r, user.ID)
+	}
+	if !a.checkLock(w, r, attempt) {
+		return db.User{}, false
+	}
+
+	if !found {
+		a.passwords.VerifyDummy(credentials.Password)
+		a.guard.fail(r.Context(), attempt)
+		writeInvalidCredentialsError(w)
+		return db.User{}, false
+	}
+
+	match, needsRehash, err := a.passwords.Verify(credentials.Password, user.PasswordHash)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return db.User{}, false
+	}
+	if !match {
+		a.guard.fail(r.Context(), attempt)
+		writeInvalidCredentialsError(w)
+		return db.User{}, false
+	}
+	a.guard.succeed(r.Context(), attempt)
+
+	if needsRehash {
+		a.rehashPassword(r, user, credentials.Password)
+	}
+
+	return user, true
+}
+
+// checkSecondFactor decodes the second login step and checks the MFA token
+// together with the code. On failure the error is already written and false
+// is returned.
+func (a *AuthHandler) checkSecondFactor(w http.ResponseWriter, r *http.Request) (db.User, bool) {
+	credentials := &LoginTwoFactorRequest{}
+
+	if !decodeAndValidate(w, r, credentials) {
+		return db.User{}, false
+	}
+
+	userID, err := a.tokens.ParseMfaToken(credentials.MfaToken)
+	if err != nil {
+		writeInvalidCredentialsError(w)
+		return db.User{}, false
+	}
+
+	attempt := userAttempt(r, userID)
+	if !a.checkLock(w, r, attempt) {
+		return db.User{}, false
+	}
+
+	userTotp, err := a.queries.GetUserTotp(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeInvalidCredentialsError(w)
+			return db.User{}, false
+		}
+		writeInternalServerError(w, err)
+		return db.User{}, false
+	}
+	if !userTotp.EnabledAt.Valid {
+		writeInvalidCredentialsError(w)
+		return db.User{}, false
+	}
+
+	ok, err := verifySecondFactor(r.Context(), a.queries, a.totp, userTotp, credentials.Code)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return db.User{}, false
+	}
+	if !ok {
+		a.guard.fail(r.Context(), attempt)
+		writeInvalidCredentialsError(w)
+		return db.User{}, false
+	}
+	a.guard.succeed(r.Context(), attempt)
+
+	user, err := a.queries.GetUser(r.Context(), userID)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return db.User{}, false
+	}
+
+	return user, true
+}
+
+// completeLogin finishes the login of a user whose first factor was checked,
+// either by issuing tokens or by starting a session. If the user has
+// two-factor authentication enabled, only an MFA token is issued.
+func (a *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, user db.User, finish func(http.ResponseWriter, *http.Request, db.User)) {
+	userTotp, err := a.queries.GetUserTotp(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeInternalServerError(w, err)
+		return
+	}
+	if err == nil && userTotp.EnabledAt.Valid {
+		mfaToken, err := a.tokens.IssueMfaToken(user.ID)
+		if err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+
+		response := MfaChallengeResponse{
+			MfaToken:  mfaToken,
+			ExpiresIn: int64(a.tokens.MfaTTL().Seconds()),
+		}
+		writeJson(w, response, http.StatusAccepted)
+		return
+	}
+
+	finish(w, r, user)
+}
+
+// respondWithTokens issues an access token and a refresh token that starts a
+// new token family.
+func (a *AuthHandler) respondWithTokens(w http.ResponseWriter, r *http.Request, user db.User) {
+	familyID, err := auth.RandomID()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response, err := a.issueTokens(r.Context(), a.queries, user, familyID)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// checkLock writes a login-locked error and returns false if the account or
+// the IP address of the attempt is locked.
+func (a *AuthHandler) checkLock(w http.ResponseWriter, r *http.Request, attempt loginAttempt) bool {
+	retryAfter, err := a.guard.lockedFor(r.Context(), attempt)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return false
+	}
+	if retryAfter > 0 {
+		writeLoginLockedError(w, retryAfter)
+		return false
+	}
+	return true
+}
+
+// issueTokens creates an access token and a refresh token that belongs to
+// the given token family.
+func (a *AuthHandler) issueTokens(ctx context.Context, q *db.Queries, user db.User, familyID string) (TokenResponse, error) {
+	accessToken, err := a.tokens.IssueAccessToken(user.ID, string(user.Role))
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	refreshToken, refreshTokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	params := db.CreateRefreshTokenParams{
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		TokenHash:  refreshTokenHash,
+		TtlSeconds: int32(a.tokens.RefreshTTL().Seconds()),
+	}
+	if _, err := q.CreateRefreshToken(ctx, params); err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.tokens.AccessTTL().Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// rehashPassword replaces a legacy or outdated password hash and records
+// the change in the audit log. The hash is only replaced if it is still the
+// one that was verified, so a password that was changed in the meantime is
+// kept. A failure only gets logged because the login itself already
+// succeeded.
+func (a *AuthHandler) rehashPassword(r *http.Request, user db.User, password string) {
+	passwordHash, err := a.passwords.Hash(password)
+	if err != nil {
+		log.Println("Error rehashing password:", err)
+		return
+	}
+
+	err = withTx(r.Context(), a.conn, a.queries, func(q *db.Queries) error {
+		current, err := q.GetUserForUpdate(r.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		if current.PasswordHash != user.PasswordHash {
+			return nil
+		}
+
+		params := db.UpdateUserPasswordParams{
+			ID:           user.ID,
+			PasswordHash: passwordHash,
+		}
+		if err := q.UpdateUserPassword(r.Context(), params); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityUser, user.ID, nil, auditCredential{Credential: "password"})
+	})
+	if err != nil {
+		log.Println("Error rehashing password:", err)
+	}
+}
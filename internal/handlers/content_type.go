@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AllowContentType rejects requests with a body of any other content type
+// with a 415, like middleware.AllowContentType. Routes that accept other
+// content types, e.g. file uploads, are listed in exceptions by a pattern of
+// their path in the syntax of path.Match.
+func AllowContentType(contentTypes []string, exceptions map[string][]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		allowed := middleware.AllowContentType(contentTypes...)(next)
+
+		routes := make(map[string]http.Handler, len(exceptions))
+		for pattern, contentTypes := range exceptions {
+			routes[pattern] = middleware.AllowContentType(contentTypes...)(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for pattern, route := range routes {
+				if ok, _ := path.Match(pattern, r.URL.Path); ok {
+					route.ServeHTTP(w, r)
+					return
+				}
+			}
+			allowed.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -11,13 +13,46 @@ import (
 type ErrorType string
 
 const (
-	JSONDecodeError    ErrorType = "json-decode-error"
-	UserNotFoundError  ErrorType = "user-not-found"
-	InvalidUserIdError ErrorType = "invalid-user-id"
-	TodoNotFoundError  ErrorType = "todo-not-found"
-	InvalidTodoIdError ErrorType = "invalid-todo-id"
-	InvalidQueryError  ErrorType = "invalid-query"
-	TodoAssignError    ErrorType = "todo-assign-error"
+	JSONDecodeError               ErrorType = "json-decode-error"
+	UserNotFoundError             ErrorType = "user-not-found"
+	InvalidUserIdError            ErrorType = "invalid-user-id"
+	TodoNotFoundError             ErrorType = "todo-not-found"
+	InvalidTodoIdError            ErrorType = "invalid-todo-id"
+	InvalidQueryError             ErrorType = "invalid-query"
+	TodoAssignError               ErrorType = "todo-assign-error"
+	UnauthorizedError             ErrorType = "unauthorized"
+	InvalidCredentialsError       ErrorType = "invalid-credentials"
+	ApiKeyNotFoundError           ErrorType = "api-key-not-found"
+	InvalidApiKeyIdError          ErrorType = "invalid-api-key-id"
+	ForbiddenError                ErrorType = "forbidden"
+	RateLimitExceededError        ErrorType = "rate-limit-exceeded"
+	TwoFactorEnabledError         ErrorType = "two-factor-enabled"
+	TwoFactorDisabledError        ErrorType = "two-factor-disabled"
+	InvalidTotpCodeError          ErrorType = "invalid-totp-code"
+	InvalidResetTokenError        ErrorType = "invalid-reset-token"
+	InvalidVerificationTokenError ErrorType = "invalid-verification-token"
+	EmailVerifiedError            ErrorType = "email-verified"
+	UserNotVerifiedError          ErrorType = "user-not-verified"
+	LoginLockedError              ErrorType = "login-locked"
+	DuplicateUserError            ErrorType = "duplicate-user"
+	OidcProviderNotFoundError     ErrorType = "oidc-provider-not-found"
+	OidcLoginError                ErrorType = "oidc-login-error"
+	InvalidCsrfTokenError         ErrorType = "invalid-csrf-token"
+	TodoAssignmentNotFoundError   ErrorType = "todo-assignment-not-found"
+	LabelNotFoundError            ErrorType = "label-not-found"
+	InvalidLabelIdError           ErrorType = "invalid-label-id"
+	DuplicateLabelError           ErrorType = "duplicate-label"
+	DuplicateTodoLabelError       ErrorType = "duplicate-todo-label"
+	TodoLabelNotFoundError        ErrorType = "todo-label-not-found"
+	TodoCycleError                ErrorType = "todo-cycle"
+	TodoDepthExceededError        ErrorType = "todo-depth-exceeded"
+	CommentNotFoundError          ErrorType = "comment-not-found"
+	InvalidCommentIdError         ErrorType = "invalid-comment-id"
+	AttachmentNotFoundError       ErrorType = "attachment-not-found"
+	InvalidAttachmentIdError      ErrorType = "invalid-attachment-id"
+	InvalidAttachmentError        ErrorType = "invalid-attachment"
+	AttachmentTooLargeError       ErrorType = "attachment-too-large"
+	UnsupportedAttachmentError    ErrorType = "unsupported-attachment-type"
 )
 
 type InternalErrorResponse struct {
@@ -122,6 +157,16 @@ func writeDuplicateTodoAssignRequestError(w http.ResponseWriter) {
 	writeJson(w, errResponse, http.StatusConflict)
 }
 
+func writeTodoAssignmentNotFoundError(w http.ResponseWriter, userID int32) {
+	errResponse := ErrorResponse{
+		Type:   TodoAssignmentNotFoundError,
+		Title:  "User not assigned",
+		Detail: fmt.Sprintf("User with id %d is not assigned to the todo", userID),
+	}
+	log.Println("Todo assignment not found:", userID)
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
 func writeInvalidQueryError(w http.ResponseWriter, actual string, options []string) {
 	errResponse := ErrorResponse{
 		Type:   InvalidQueryError,
@@ -131,3 +176,341 @@ func writeInvalidQueryError(w http.ResponseWriter, actual string, options []stri
 	log.Printf("Invalid query: actual=%s options=%v\n", actual, options)
 	writeJson(w, errResponse, http.StatusBadRequest)
 }
+
+func writeInvalidQueryParamError(w http.ResponseWriter, name string, actual string) {
+	errResponse := ErrorResponse{
+		Type:   InvalidQueryError,
+		Title:  "Invalid query",
+		Detail: fmt.Sprintf("The value %s of the query parameter %s is not valid", actual, name),
+	}
+	log.Printf("Invalid query: name=%s actual=%s\n", name, actual)
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeUnauthorizedError(w http.ResponseWriter, detail string) {
+	errResponse := ErrorResponse{
+		Type:   UnauthorizedError,
+		Title:  "Unauthorized",
+		Detail: detail,
+	}
+	log.Println("Unauthorized:", detail)
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeJson(w, errResponse, http.StatusUnauthorized)
+}
+
+func writeInvalidCredentialsError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   InvalidCredentialsError,
+		Title:  "Invalid credentials",
+		Detail: "The username or password is incorrect",
+	}
+	log.Println("Invalid credentials")
+	writeJson(w, errResponse, http.StatusUnauthorized)
+}
+
+func writeApiKeyNotFoundError(w http.ResponseWriter, id int32) {
+	errResponse := ErrorResponse{
+		Type:   ApiKeyNotFoundError,
+		Title:  "API key not found",
+		Detail: fmt.Sprintf("API key with id %d not found", id),
+	}
+	log.Println("API key not found:", id)
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
+func writeInvalidApiKeyIdError(w http.ResponseWriter, id string) {
+	errResponse := ErrorResponse{
+		Type:   InvalidApiKeyIdError,
+		Title:  "Invalid API key id",
+		Detail: fmt.Sprintf("The API key id %s is not valid", id),
+	}
+	log.Println("Invalid API key id:", id)
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeForbiddenError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   ForbiddenError,
+		Title:  "Forbidden",
+		Detail: "You are not allowed to perform this action",
+	}
+	log.Println("Forbidden")
+	writeJson(w, errResponse, http.StatusForbidden)
+}
+
+func writeRateLimitExceededError(w http.ResponseWriter, retryAfter int) {
+	errResponse := ErrorResponse{
+		Type:   RateLimitExceededError,
+		Title:  "Too many requests",
+		Detail: fmt.Sprintf("The rate limit was exceeded. Try again in %d seconds", retryAfter),
+	}
+	log.Println("Rate limit exceeded")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeJson(w, errResponse, http.StatusTooManyRequests)
+}
+
+func writeTwoFactorEnabledError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   TwoFactorEnabledError,
+		Title:  "Two-factor authentication enabled",
+		Detail: "Two-factor authentication is already enabled for the user",
+	}
+	log.Println("Two-factor authentication already enabled")
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeTwoFactorDisabledError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   TwoFactorDisabledError,
+		Title:  "Two-factor authentication disabled",
+		Detail: "Two-factor authentication is not enabled for the user",
+	}
+	log.Println("Two-factor authentication not enabled")
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
+func writeInvalidTotpCodeError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   InvalidTotpCodeError,
+		Title:  "Invalid code",
+		Detail: "The code is incorrect or was already used",
+	}
+	log.Println("Invalid TOTP code")
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeInvalidResetTokenError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   InvalidResetTokenError,
+		Title:  "Invalid reset token",
+		Detail: "The password reset token is invalid, expired or was already used",
+	}
+	log.Println("Invalid password reset token")
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeInvalidVerificationTokenError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   InvalidVerificationTokenError,
+		Title:  "Invalid verification token",
+		Detail: "The email verification token is invalid, expired or belongs to an old email address",
+	}
+	log.Println("Invalid email verification token")
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeEmailVerifiedError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   EmailVerifiedError,
+		Title:  "Email already verified",
+		Detail: "The email address of the user is already verified",
+	}
+	log.Println("Email already verified")
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeUserNotVerifiedError(w http.ResponseWriter, id int32) {
+	errResponse := ErrorResponse{
+		Type:   UserNotVerifiedError,
+		Title:  "User not verified",
+		Detail: fmt.Sprintf("User with id %d has not verified their email address yet", id),
+	}
+	log.Println("User not verified:", id)
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeLoginLockedError(w http.ResponseWriter, retryAfter int) {
+	errResponse := ErrorResponse{
+		Type:   LoginLockedError,
+		Title:  "Login locked",
+		Detail: fmt.Sprintf("Too many failed login attempts. Try again in %d seconds", retryAfter),
+	}
+	log.Println("Login locked")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeJson(w, errResponse, http.StatusTooManyRequests)
+}
+
+func writeInvalidUserRequestError(w http.ResponseWriter, err *pgconn.PgError) {
+	log.Println("Invalid user request:", err)
+	switch err.ConstraintName {
+	case "user_username_key":
+		writeDuplicateUserError(w, "username")
+	case "user_email_key":
+		writeDuplicateUserError(w, "email")
+	default:
+		writeInternalServerError(w, err)
+	}
+}
+
+func writeDuplicateUserError(w http.ResponseWriter, field string) {
+	errResponse := ErrorResponse{
+		Type:   DuplicateUserError,
+		Title:  "User already exists",
+		Detail: fmt.Sprintf("A user with this %s already exists", field),
+	}
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeOidcProviderNotFoundError(w http.ResponseWriter, name string) {
+	errResponse := ErrorResponse{
+		Type:   OidcProviderNotFoundError,
+		Title:  "Identity provider not found",
+		Detail: fmt.Sprintf("Identity provider %s not found", name),
+	}
+	log.Println("Identity provider not found:", name)
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
+func writeOidcLoginError(w http.ResponseWriter, detail string) {
+	errResponse := ErrorResponse{
+		Type:   OidcLoginError,
+		Title:  "Login failed",
+		Detail: detail,
+	}
+	log.Println("OIDC login failed:", detail)
+	writeJson(w, errResponse, http.StatusUnauthorized)
+}
+
+func writeInvalidCsrfTokenError(w http.ResponseWriter) {
+	errResponse := ErrorResponse{
+		Type:   InvalidCsrfTokenError,
+		Title:  "Invalid CSRF token",
+		Detail: "The X-CSRF-Token header is missing or doesn't match the session",
+	}
+	log.Println("Invalid CSRF token")
+	writeJson(w, errResponse, http.StatusForbidden)
+}
+
+func writeLabelNotFoundError(w http.ResponseWriter, id int32) {
+	errResponse := ErrorResponse{
+		Type:   LabelNotFoundError,
+		Title:  "Label not found",
+		Detail: fmt.Sprintf("Label with id %d not found", id),
+	}
+	log.Println("Label not found:", id)
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
+func writeInvalidLabelIdError(w http.ResponseWriter, id string) {
+	errResponse := ErrorResponse{
+		Type:   InvalidLabelIdError,
+		Title:  "Invalid label id",
+		Detail: fmt.Sprintf("The label id %s is not valid", id),
+	}
+	log.Println("Invalid label id:", id)
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeDuplicateLabelError(w http.ResponseWriter, name string) {
+	errResponse := ErrorResponse{
+		Type:   DuplicateLabelError,
+		Title:  "Label already exists",
+		Detail: fmt.Sprintf("A label with the name %s already exists", name),
+	}
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeDuplicateTodoLabelError(w http.ResponseWriter, labelID int32) {
+	errResponse := ErrorResponse{
+		Type:   DuplicateTodoLabelError,
+		Title:  "Label already attached",
+		Detail: fmt.Sprintf("Label with id %d is already attached to the todo", labelID),
+	}
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeTodoLabelNotFoundError(w http.ResponseWriter, labelID int32) {
+	errResponse := ErrorResponse{
+		Type:   TodoLabelNotFoundError,
+		Title:  "Label not attached",
+		Detail: fmt.Sprintf("Label with id %d is not attached to the todo", labelID),
+	}
+	log.Println("Todo label not found:", labelID)
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
+func writeTodoCycleError(w http.ResponseWriter, todoID int32, parentID int32) {
+	errResponse := ErrorResponse{
+		Type:   TodoCycleError,
+		Title:  "Todo cycle",
+		Detail: fmt.Sprintf("Todo with id %d can't become a subtask of todo with id %d, which is the todo itself or one of its subtasks", todoID, parentID),
+	}
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeTodoDepthExceededError(w http.ResponseWriter, maxDepth int32) {
+	errResponse := ErrorResponse{
+		Type:   TodoDepthExceededError,
+		Title:  "Todo nested too deeply",
+		Detail: fmt.Sprintf("Subtasks can't be nested more than %d levels deep", maxDepth),
+	}
+	writeJson(w, errResponse, http.StatusConflict)
+}
+
+func writeCommentNotFoundError(w http.ResponseWriter, id int32) {
+	errResponse := ErrorResponse{
+		Type:   CommentNotFoundError,
+		Title:  "Comment not found",
+		Detail: fmt.Sprintf("Comment with id %d not found", id),
+	}
+	log.Println("Comment not found:", id)
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
+func writeInvalidCommentIdError(w http.ResponseWriter, id string) {
+	errResponse := ErrorResponse{
+		Type:   InvalidCommentIdError,
+		Title:  "Invalid comment id",
+		Detail: fmt.Sprintf("The comment id %s is not valid", id),
+	}
+	log.Println("Invalid comment id:", id)
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeAttachmentNotFoundError(w http.ResponseWriter, id int32) {
+	errResponse := ErrorResponse{
+		Type:   AttachmentNotFoundError,
+		Title:  "Attachment not found",
+		Detail: fmt.Sprintf("Attachment with id %d not found", id),
+	}
+	log.Println("Attachment not found:", id)
+	writeJson(w, errResponse, http.StatusNotFound)
+}
+
+func writeInvalidAttachmentIdError(w http.ResponseWriter, id string) {
+	errResponse := ErrorResponse{
+		Type:   InvalidAttachmentIdError,
+		Title:  "Invalid attachment id",
+		Detail: fmt.Sprintf("The attachment id %s is not valid", id),
+	}
+	log.Println("Invalid attachment id:", id)
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeInvalidAttachmentError(w http.ResponseWriter, err error) {
+	errResponse := ErrorResponse{
+		Type:   InvalidAttachmentError,
+		Title:  "Invalid attachment",
+		Detail: err.Error(),
+	}
+	log.Println("Invalid attachment:", err)
+	writeJson(w, errResponse, http.StatusBadRequest)
+}
+
+func writeAttachmentTooLargeError(w http.ResponseWriter, maxSize int64) {
+	errResponse := ErrorResponse{
+		Type:   AttachmentTooLargeError,
+		Title:  "Attachment too large",
+		Detail: fmt.Sprintf("Attachments may not be larger than %d bytes", maxSize),
+	}
+	writeJson(w, errResponse, http.StatusRequestEntityTooLarge)
+}
+
+func writeUnsupportedAttachmentError(w http.ResponseWriter, contentType string, allowed []string) {
+	errResponse := ErrorResponse{
+		Type:   UnsupportedAttachmentError,
+		Title:  "Unsupported attachment type",
+		Detail: fmt.Sprintf("Attachments of type %s are not allowed, allowed types are %s", contentType, strings.Join(allowed, ", ")),
+	}
+	writeJson(w, errResponse, http.StatusUnsupportedMediaType)
+}
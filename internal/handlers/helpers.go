@@ -1,9 +1,18 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mderler/simple-go-backend/internal/db"
 )
 
 func decodeAndValidate(w http.ResponseWriter, r *http.Request, v interface{}) bool {
@@ -20,6 +29,113 @@ func decodeAndValidate(w http.ResponseWriter, r *http.Request, v interface{}) bo
 	return true
 }
 
+// mergePatch is a JSON Merge Patch (RFC 7396) that was read from a request
+// body and already validated.
+type mergePatch []byte
+
+// applyTo applies the patch to v, which has to point to a struct of the same
+// type that was validated and holds the current state of the resource.
+func (p mergePatch) applyTo(v interface{}) error {
+	return json.Unmarshal(p, v)
+}
+
+// decodeMergePatch reads a JSON Merge Patch (RFC 7396) from the request body
+// and validates it against v, which has to point to the zero value of the
+// patch struct. Only the members that were sent get validated, so the patch
+// can be applied to the current state of the resource later on, e.g. once it
+// is locked. Only optional fields (pointers and slices) can be removed with
+// null, for other fields it is rejected like a missing required field.
+func decodeMergePatch(w http.ResponseWriter, r *http.Request, v interface{}) (mergePatch, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteJsonDecodeError(w, err)
+		return nil, false
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil {
+		WriteJsonDecodeError(w, err)
+		return nil, false
+	}
+
+	fields := jsonFields(reflect.TypeOf(v).Elem())
+	var sent, removed []string
+	for member, value := range patch {
+		field, ok := fields[strings.ToLower(member)]
+		if !ok {
+			continue
+		}
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) && !isNullable(field.Type) {
+			removed = append(removed, field.Name)
+			continue
+		}
+		sent = append(sent, field.Name)
+	}
+	if len(removed) > 0 {
+		writeJson(w, newValidationErrorResponse("required", removed...), http.StatusUnprocessableEntity)
+		return nil, false
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		WriteJsonDecodeError(w, err)
+		return nil, false
+	}
+
+	if msg := validatePartial(v, sent...); msg != nil {
+		writeJson(w, msg, http.StatusUnprocessableEntity)
+		return nil, false
+	}
+
+	return mergePatch(body), true
+}
+
+// jsonFields maps the lowercased JSON names of the fields of a struct to the
+// fields. encoding/json matches names without case as well, so the lookup has
+// to do the same.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field
+	}
+	return fields
+}
+
+func isNullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	default:
+		return false
+	}
+}
+
+// utcTime converts an optional time of a request to UTC. Timestamps in the
+// database are stored without a time zone and pgx drops the zone instead of
+// converting the time.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// sameTime reports whether two optional times are both unset or equal.
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
 func writeJson(w http.ResponseWriter, v interface{}, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -28,3 +144,18 @@ func writeJson(w http.ResponseWriter, v interface{}, statusCode int) {
 		w.Write([]byte(`{"type":"internal-server-error","title":"Something went wrong"}`))
 	}
 }
+
+// withTx runs fn inside a transaction and commits it if fn returns no error.
+func withTx(ctx context.Context, conn *pgxpool.Pool, queries *db.Queries, fn func(*db.Queries) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(queries.WithTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// LabelHandler manages the labels that can be attached to todos. Labels are
+// shared between all users, but only their creator and administrators may
+// change them.
+type LabelHandler struct {
+	*chi.Mux
+	conn    *pgxpool.Pool
+	queries *db.Queries
+}
+
+func NewLabelHandler(conn *pgxpool.Pool, queries *db.Queries, authn *Authenticator, limiter *RateLimiter) *LabelHandler {
+	labelHandler := &LabelHandler{chi.NewRouter(), conn, queries}
+
+	labelHandler.Use(authn.Middleware)
+	labelHandler.Use(csrfProtect)
+	labelHandler.Use(limiter.Limit("label"))
+
+	labelHandler.Post("/", labelHandler.createLabel)
+	labelHandler.Get("/", labelHandler.getLabels)
+
+	labelHandler.Group(func(r chi.Router) {
+		r.Use(labelCtx)
+		r.Use(labelHandler.labelLookupCtx)
+		r.Get("/{id}", labelHandler.getLabel)
+		r.With(authorize(isLabelCreator, isAdmin)).Put("/{id}", labelHandler.updateLabel)
+		r.With(authorize(isLabelCreator, isAdmin)).Delete("/{id}", labelHandler.deleteLabel)
+	})
+	return labelHandler
+}
+
+// labelLookupCtx loads the label that the {id} of the route refers to.
+func (l *LabelHandler) labelLookupCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		labelID := r.Context().Value(labelIDKey).(int32)
+
+		label, err := l.queries.GetLabel(r.Context(), labelID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeLabelNotFoundError(w, labelID)
+				return
+			}
+			writeInternalServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), labelKey, label)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// @Summary Create a new label
+// @Description Create a new label. Label names are unique regardless of case.
+// @Tags Label
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param label body LabelRequest true "Label data"
+// @Success 201 {object} db.Label "Created label"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 409 {object} ErrorResponse "Duplicate label"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /label [post]
+func (l *LabelHandler) createLabel(w http.ResponseWriter, r *http.Request) {
+	creatorID := r.Context().Value(authUserIDKey).(int32)
+
+	label := &LabelRequest{}
+
+	if !decodeAndValidate(w, r, label) {
+		return
+	}
+
+	params := db.CreateLabelParams{
+		CreatorID: &creatorID,
+		Name:      label.Name,
+		Color:     strings.ToLower(label.Color),
+	}
+	var dbLabel db.Label
+	err := withTx(r.Context(), l.conn, l.queries, func(q *db.Queries) error {
+		var err error
+		dbLabel, err = q.CreateLabel(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityLabel, dbLabel.ID, nil, dbLabel)
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			writeDuplicateLabelError(w, label.Name)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, dbLabel, http.StatusCreated)
+}
+
+// @Summary Get all labels
+// @Description Get the list of all labels, sorted by name.
+// @Tags Label
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} db.Label "List of labels"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /label [get]
+func (l *LabelHandler) getLabels(w http.ResponseWriter, r *http.Request) {
+	labels, err := l.queries.ListLabels(r.Context())
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, labels, http.StatusOK)
+}
+
+// @Summary Get a label
+// @Description Get a label by its ID.
+// @Tags Label
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Label ID"
+// @Success 200 {object} db.Label "Label"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 404 {object} ErrorResponse "Label not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /label/{id} [get]
+func (l *LabelHandler) getLabel(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, r.Context().Value(labelKey).(db.Label), http.StatusOK)
+}
+
+// @Summary Update a label
+// @Description Update the name and color of a label. Only its creator and
+// @Description administrators may do this. Labels outlive their creator and
+// @Description are then left to administrators.
+// @Tags Label
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Label ID"
+// @Param label body LabelRequest true "Label data"
+// @Success 200 {object} db.Label "Updated label"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Label not found"
+// @Failure 409 {object} ErrorResponse "Duplicate label"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /label/{id} [put]
+func (l *LabelHandler) updateLabel(w http.ResponseWriter, r *http.Request) {
+	labelID := r.Context().Value(labelIDKey).(int32)
+
+	label := &LabelRequest{}
+
+	if !decodeAndValidate(w, r, label) {
+		return
+	}
+
+	params := db.UpdateLabelParams{
+		ID:    labelID,
+		Name:  label.Name,
+		Color: strings.ToLower(label.Color),
+	}
+	var dbLabel db.Label
+	err := withTx(r.Context(), l.conn, l.queries, func(q *db.Queries) error {
+		before, err := q.GetLabel(r.Context(), labelID)
+		if err != nil {
+			return err
+		}
+
+		dbLabel, err = q.UpdateLabel(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityLabel, labelID, before, dbLabel)
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeLabelNotFoundError(w, labelID)
+			return
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			writeDuplicateLabelError(w, label.Name)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, dbLabel, http.StatusOK)
+}
+
+// @Summary Delete a label
+// @Description Delete a label and detach it from all todos. Only its creator
+// @Description and administrators may do this.
+// @Tags Label
+// @Security BearerAuth
+// @Param id path int true "Label ID"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Label not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /label/{id} [delete]
+func (l *LabelHandler) deleteLabel(w http.ResponseWriter, r *http.Request) {
+	labelID := r.Context().Value(labelIDKey).(int32)
+
+	err := withTx(r.Context(), l.conn, l.queries, func(q *db.Queries) error {
+		before, err := q.GetLabel(r.Context(), labelID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.DeleteLabel(r.Context(), labelID); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionDelete, auditEntityLabel, labelID, before, nil)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeLabelNotFoundError(w, labelID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// @Summary Get locked users
+// @Description Get the users whose login is currently locked because of too
+// @Description many failed attempts. Only administrators may do this.
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} LockoutResponse "List of locked users"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/lockouts [get]
+func (u *UserHandler) getLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := u.queries.ListLockedUsers(r.Context())
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := make([]LockoutResponse, len(lockouts))
+	for i, lockout := range lockouts {
+		response[i] = newLockoutResponse(lockout)
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Unlock a user
+// @Description Unlock the login of a user and reset their failed attempts.
+// @Description Locks of IP addresses stay in place. Only administrators may
+// @Description do this.
+// @Tags User
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/lockout [delete]
+func (u *UserHandler) unlockUser(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+	actorID := r.Context().Value(authUserIDKey).(int32)
+
+	key := userLockoutKey(userID)
+	err := withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		failures, err := q.ResetLoginThrottle(r.Context(), key)
+		if err != nil {
+			return err
+		}
+
+		params := db.CreateLockoutEventParams{
+			Action:   db.LockoutActionUnlocked,
+			Key:      key,
+			UserID:   pgtype.Int4{Int32: userID, Valid: true},
+			ActorID:  pgtype.Int4{Int32: actorID, Valid: true},
+			Failures: failures,
+		}
+		return q.CreateLockoutEvent(r.Context(), params)
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
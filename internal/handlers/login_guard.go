@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// LockoutPolicy describes when failed logins lock a key. Once Threshold
+// failures happened without a pause of Window in between, the key is locked
+// for BaseDelay. Every further failure doubles the delay up to MaxDelay. A
+// Threshold of 0 disables the lockout.
+type LockoutPolicy struct {
+	Threshold int32
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+func (p LockoutPolicy) delay(failures int32) time.Duration {
+	if p.Threshold <= 0 || failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// LoginGuard protects the login against guessing credentials. Failed attempts
+// are counted per account and per IP address, and each of them gets locked
+// on its own. Usernames that don't exist are counted and locked the same way
+// as accounts, so the responses don't reveal which usernames exist.
+type LoginGuard struct {
+	queries *db.Queries
+	account LockoutPolicy
+	ip      LockoutPolicy
+}
+
+func NewLoginGuard(queries *db.Queries, account LockoutPolicy, ip LockoutPolicy) *LoginGuard {
+	return &LoginGuard{queries, account, ip}
+}
+
+// loginAttempt identifies the account and the client of a login.
+type loginAttempt struct {
+	accountKey string
+	userID     pgtype.Int4
+	ip         string
+}
+
+func userAttempt(r *http.Request, userID int32) loginAttempt {
+	return loginAttempt{
+		accountKey: userLockoutKey(userID),
+		userID:     pgtype.Int4{Int32: userID, Valid: true},
+		ip:         clientIP(r),
+	}
+}
+
+func nameAttempt(r *http.Request, username string) loginAttempt {
+	return loginAttempt{
+		accountKey: "name:" + strings.ToLower(username),
+		ip:         clientIP(r),
+	}
+}
+
+func userLockoutKey(userID int32) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// lockedFor returns the number of seconds until the account and the IP
+// address of the attempt are both unlocked again.
+func (g *LoginGuard) lockedFor(ctx context.Context, attempt loginAttempt) (int, error) {
+	seconds, err := g.queries.GetLoginLockSeconds(ctx, []string{attempt.accountKey, "ip:" + attempt.ip})
+	return int(seconds), err
+}
+
+// fail counts a failed attempt and locks the account or the IP address if
+// their policy says so. Errors only get logged because the attempt already
+// failed anyway.
+func (g *LoginGuard) fail(ctx context.Context, attempt loginAttempt) {
+	g.record(ctx, attempt, attempt.accountKey, g.account)
+	g.record(ctx, attempt, "ip:"+attempt.ip, g.ip)
+}
+
+// succeed resets the failed attempts of the account. The ones of the IP
+// address are kept, otherwise a single valid account would be enough to keep
+// guessing the passwords of others.
+func (g *LoginGuard) succeed(ctx context.Context, attempt loginAttempt) {
+	if _, err := g.queries.ResetLoginThrottle(ctx, attempt.accountKey); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Println("Error resetting failed logins:", err)
+	}
+}
+
+func (g *LoginGuard) record(ctx context.Context, attempt loginAttempt, key string, policy LockoutPolicy) {
+	params := db.RecordLoginFailureParams{
+		Key:           key,
+		WindowSeconds: int32(policy.Window.Seconds()),
+	}
+	failures, err := g.queries.RecordLoginFailure(ctx, params)
+	if err != nil {
+		log.Println("Error recording failed login:", err)
+		return
+	}
+
+	delay := policy.delay(failures)
+	if delay == 0 {
+		return
+	}
+
+	lockParams := db.LockLoginParams{
+		Key:         key,
+		LockSeconds: int32(math.Ceil(delay.Seconds())),
+	}
+	lockedUntil, err := g.queries.LockLogin(ctx, lockParams)
+	if err != nil {
+		log.Println("Error locking login:", err)
+		return
+	}
+	log.Printf("Locked login of %s for %s after %d failed attempts\n", key, delay, failures)
+
+	eventParams := db.CreateLockoutEventParams{
+		Action:      db.LockoutActionLocked,
+		Key:         key,
+		UserID:      attempt.userID,
+		Ip:          pgtype.Text{String: attempt.ip, Valid: true},
+		Failures:    failures,
+		LockedUntil: lockedUntil,
+	}
+	if err := g.queries.CreateLockoutEvent(ctx, eventParams); err != nil {
+		log.Println("Error recording lockout event:", err)
+	}
+}
+
+// Cleanup deletes the failed attempts that are neither locked nor recent
+// enough to count anymore.
+func (g *LoginGuard) Cleanup(ctx context.Context) (int64, error) {
+	window := max(g.account.Window, g.ip.Window)
+	return g.queries.DeleteStaleLoginThrottles(ctx, int32(window.Seconds()))
+}
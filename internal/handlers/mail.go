@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
+	"github.com/mderler/simple-go-backend/internal/mailer"
+)
+
+// mailTimeout limits how long sending a single email may take.
+const mailTimeout = 30 * time.Second
+
+// EmailVerifier mails signed links that confirm the email address of a user.
+// The links point to VerificationURL with the token as the query parameter
+// token, see AuthHandler.verifyEmail.
+type EmailVerifier struct {
+	tokens *auth.TokenManager
+	mailer mailer.Mailer
+	url    string
+}
+
+func NewEmailVerifier(tokens *auth.TokenManager, mailer mailer.Mailer, url string) *EmailVerifier {
+	return &EmailVerifier{tokens, mailer, url}
+}
+
+// Send mails a verification link for the current email address of the user.
+// It is meant to run in the background, so errors only get logged.
+func (v *EmailVerifier) Send(user db.User) {
+	token, err := v.tokens.IssueVerificationToken(user.ID, user.Email)
+	if err != nil {
+		log.Println("Error sending verification mail:", err)
+		return
+	}
+
+	link, err := linkWithToken(v.url, token)
+	if err != nil {
+		log.Println("Error sending verification mail:", err)
+		return
+	}
+
+	sendMail(v.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"please confirm your email address by opening the following link:\n\n%s\n\n"+
+			"The link expires in %s.\n",
+			user.Username, link, v.tokens.VerificationTTL()),
+	})
+}
+
+// linkWithToken appends the token to the base URL as the query parameter
+// token.
+func linkWithToken(base string, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
+
+func sendMail(m mailer.Mailer, message mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	if err := m.Send(ctx, message); err != nil {
+		log.Println("Error sending mail:", err)
+	}
+}
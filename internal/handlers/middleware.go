@@ -2,51 +2,242 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
 )
 
 type contextKey string
 
 const (
-	userIDKey contextKey = "userID"
-	todoIDKey contextKey = "todoID"
+	userIDKey       contextKey = "userID"
+	todoIDKey       contextKey = "todoID"
+	apiKeyIDKey     contextKey = "apiKeyID"
+	assigneeIDKey   contextKey = "assigneeID"
+	authUserIDKey   contextKey = "authUserID"
+	authUserRoleKey contextKey = "authUserRole"
+	authApiKeyIDKey contextKey = "authApiKeyID"
+	authSessionKey  contextKey = "authSession"
+	todoAccessKey   contextKey = "todoAccess"
+	labelIDKey      contextKey = "labelID"
+	labelKey        contextKey = "label"
+	commentIDKey    contextKey = "commentID"
+	commentKey      contextKey = "comment"
+	attachmentIDKey contextKey = "attachmentID"
+	attachmentKey   contextKey = "attachment"
 )
 
-func userCtx(next http.Handler) http.Handler {
+// todoAccess describes how the authenticated user is related to a todo. A
+// user that created or is assigned to one of the parents of the todo is an
+// ancestor member and may read the todo.
+type todoAccess struct {
+	creator        bool
+	assignee       bool
+	ancestorMember bool
+}
+
+func newTodoAccess(row db.GetTodoAccessRow, userID int32) todoAccess {
+	return todoAccess{
+		creator:        row.CreatorID == userID,
+		assignee:       row.Assigned,
+		ancestorMember: row.Inherited,
+	}
+}
+
+// visible tells whether the user may see the todo at all. Others get a 404,
+// so that the todos of other users aren't revealed.
+func (a todoAccess) visible() bool {
+	return a.creator || a.assignee || a.ancestorMember
+}
+
+// Authenticator resolves the caller of a request from its credentials.
+type Authenticator struct {
+	queries  *db.Queries
+	tokens   *auth.TokenManager
+	sessions SessionConfig
+}
+
+func NewAuthenticator(queries *db.Queries, tokens *auth.TokenManager, sessions SessionConfig) *Authenticator {
+	return &Authenticator{queries, tokens, sessions}
+}
+
+// Middleware rejects requests without a valid bearer token, API key or session
+// cookie and stores the ID and the role of the authenticated user in the
+// request context. The session cookie is only used if the request has no
+// Authorization header.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID := chi.URLParam(r, "id")
-		if userID == "" {
-			writeInvalidUserIdError(w, userID)
+		scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+		ctx := r.Context()
+		var userID int32
+		var role db.UserRole
+		var err error
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			var claimedRole string
+			userID, claimedRole, err = a.tokens.ParseAccessToken(credentials)
+			role = db.UserRole(claimedRole)
+		case strings.EqualFold(scheme, "ApiKey"):
+			var apiKey db.GetActiveApiKeyByPrefixRow
+			apiKey, err = a.authenticateApiKey(r.Context(), credentials)
+			userID, role = apiKey.UserID, apiKey.Role
+			ctx = context.WithValue(ctx, authApiKeyIDKey, apiKey.ID)
+		case scheme == "":
+			cookie, cookieErr := r.Cookie(a.sessions.CookieName)
+			if cookieErr != nil {
+				writeUnauthorizedError(w, "A bearer token, API key or session cookie is required")
+				return
+			}
+			var session db.GetActiveSessionRow
+			session, err = a.authenticateSession(r.Context(), cookie.Value)
+			userID, role = session.UserID, session.Role
+			ctx = context.WithValue(ctx, authSessionKey, session)
+		default:
+			writeUnauthorizedError(w, "A bearer token, API key or session cookie is required")
 			return
 		}
-		id, err := strconv.ParseInt(userID, 10, 32)
 		if err != nil {
-			writeInvalidUserIdError(w, userID)
+			if errors.Is(err, auth.ErrInvalidToken) {
+				writeUnauthorizedError(w, "The credentials are invalid or expired")
+				return
+			}
+			writeInternalServerError(w, err)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), userIDKey, int32(id))
+		ctx = context.WithValue(ctx, authUserIDKey, userID)
+		ctx = context.WithValue(ctx, authUserRoleKey, role)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func todoCtx(next http.Handler) http.Handler {
+// Session stores the user of a valid session cookie in the request context
+// like Middleware, but lets requests without one through. It lets csrfProtect
+// check requests that work with and without a session. Authorization headers
+// are ignored.
+func (a *Authenticator) Session(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		todoID := chi.URLParam(r, "id")
-		if todoID == "" {
-			writeInvalidTodoIdError(w, todoID)
+		cookie, err := r.Cookie(a.sessions.CookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
 			return
 		}
-		id, err := strconv.ParseInt(todoID, 10, 32)
+
+		session, err := a.authenticateSession(r.Context(), cookie.Value)
 		if err != nil {
-			writeInvalidTodoIdError(w, todoID)
+			if errors.Is(err, auth.ErrInvalidToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			writeInternalServerError(w, err)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), todoIDKey, int32(id))
+		ctx := context.WithValue(r.Context(), authSessionKey, session)
+		ctx = context.WithValue(ctx, authUserIDKey, session.UserID)
+		ctx = context.WithValue(ctx, authUserRoleKey, session.Role)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func (a *Authenticator) authenticateApiKey(ctx context.Context, key string) (db.GetActiveApiKeyByPrefixRow, error) {
+	prefix, ok := auth.ParseApiKey(key)
+	if !ok {
+		return db.GetActiveApiKeyByPrefixRow{}, auth.ErrInvalidToken
+	}
+
+	apiKey, err := a.queries.GetActiveApiKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.GetActiveApiKeyByPrefixRow{}, auth.ErrInvalidToken
+		}
+		return db.GetActiveApiKeyByPrefixRow{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(auth.HashOpaqueToken(key)), []byte(apiKey.KeyHash)) != 1 {
+		return db.GetActiveApiKeyByPrefixRow{}, auth.ErrInvalidToken
+	}
+
+	if err := a.queries.TouchApiKey(ctx, apiKey.ID); err != nil {
+		log.Println("Error updating API key usage:", err)
+	}
+
+	return apiKey, nil
+}
+
+func (a *Authenticator) authenticateSession(ctx context.Context, token string) (db.GetActiveSessionRow, error) {
+	session, err := a.queries.GetActiveSession(ctx, auth.HashOpaqueToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.GetActiveSessionRow{}, auth.ErrInvalidToken
+		}
+		return db.GetActiveSessionRow{}, err
+	}
+	return session, nil
+}
+
+// csrfProtect requires the CSRF token of the session in the X-CSRF-Token
+// header for state-changing requests that were authenticated with a session
+// cookie. Bearer tokens and API keys aren't affected because browsers never
+// attach them on their own. It has to run after the authenticator.
+func csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := r.Context().Value(authSessionKey).(db.GetActiveSessionRow)
+		if ok && !isSafeMethod(r.Method) {
+			token := r.Header.Get(csrfTokenHeader)
+			if subtle.ConstantTimeCompare([]byte(token), []byte(session.CsrfToken)) != 1 {
+				writeInvalidCsrfTokenError(w)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	userCtx       = idParamCtx("id", userIDKey, writeInvalidUserIdError)
+	todoCtx       = idParamCtx("id", todoIDKey, writeInvalidTodoIdError)
+	apiKeyCtx     = idParamCtx("keyId", apiKeyIDKey, writeInvalidApiKeyIdError)
+	assigneeCtx   = idParamCtx("userId", assigneeIDKey, writeInvalidUserIdError)
+	labelCtx      = idParamCtx("id", labelIDKey, writeInvalidLabelIdError)
+	todoLabelCtx  = idParamCtx("labelId", labelIDKey, writeInvalidLabelIdError)
+	commentCtx    = idParamCtx("commentId", commentIDKey, writeInvalidCommentIdError)
+	attachmentCtx = idParamCtx("attachmentId", attachmentIDKey, writeInvalidAttachmentIdError)
+)
+
+// idParamCtx returns a middleware that parses the ID in the URL parameter
+// with the given name and stores it in the request context under the key.
+// IDs that aren't a 32-bit integer are answered with writeInvalid.
+func idParamCtx(param string, key contextKey, writeInvalid func(w http.ResponseWriter, id string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := chi.URLParam(r, param)
+			id, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				writeInvalid(w, value)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), key, int32(id))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+var (
+	errOidcEmailMissing = errors.New("the identity provider didn't share an email address")
+	errOidcEmailTaken   = errors.New("the email address belongs to another user")
+)
+
+// oidcRequestTTL limits how long users may take to sign in at the identity
+// provider.
+const oidcRequestTTL = 10 * time.Minute
+
+// oidcUserAttempts limits how often the user of a first login is created
+// again after its username was taken in the meantime.
+const oidcUserAttempts = 3
+
+// oidcStateCookieName names the cookie that binds a login request to the
+// browser that started it, so that a callback can't be completed elsewhere.
+const oidcStateCookieName = "oidc_state"
+
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// @Summary Log in with an identity provider
+// @Description Redirect to the OpenID Connect provider to sign in there. The
+// @Description provider redirects back to the callback endpoint afterwards.
+// @Description A short-lived cookie ties the login to the browser.
+// @Tags Auth
+// @Param provider path string true "Provider name"
+// @Success 302 "Redirect to the provider"
+// @Failure 404 {object} ErrorResponse "Provider not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/oidc/{provider}/login [get]
+func (a *AuthHandler) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := a.providers[name]
+	if !ok {
+		writeOidcProviderNotFoundError(w, name)
+		return
+	}
+
+	request, err := auth.NewOidcAuthRequest()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(request)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	params := db.CreateOidcAuthRequestParams{
+		State:        request.State,
+		Provider:     name,
+		Nonce:        request.Nonce,
+		CodeVerifier: request.CodeVerifier,
+		TtlSeconds:   int32(oidcRequestTTL.Seconds()),
+	}
+	if err := a.queries.CreateOidcAuthRequest(r.Context(), params); err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	http.SetCookie(w, a.oidcStateCookie(request.State, int(oidcRequestTTL.Seconds())))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// @Summary Complete a login with an identity provider
+// @Description Handle the redirect of the OpenID Connect provider and issue
+// @Description tokens. Users are created on their first login and linked to
+// @Description the identity. An existing user with the same email address is
+// @Description only linked if both sides verified it. Users with two-factor
+// @Description authentication get an MFA token instead. The login has to be
+// @Description completed in the browser that started it.
+// @Tags Auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State of the login request"
+// @Success 200 {object} TokenResponse "Issued tokens"
+// @Success 202 {object} MfaChallengeResponse "Second factor required"
+// @Failure 401 {object} ErrorResponse "Login failed"
+// @Failure 404 {object} ErrorResponse "Provider not found"
+// @Failure 409 {object} ErrorResponse "Email already taken"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/oidc/{provider}/callback [get]
+func (a *AuthHandler) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	provider, ok := a.providers[name]
+	if !ok {
+		writeOidcProviderNotFoundError(w, name)
+		return
+	}
+
+	// The login request can only be used once, so the cookie is no longer
+	// needed whatever the outcome is.
+	cookie, cookieErr := r.Cookie(oidcStateCookieName)
+	http.SetCookie(w, a.oidcStateCookie("", -1))
+
+	query := r.URL.Query()
+	if errorCode := query.Get("error"); errorCode != "" {
+		writeOidcLoginError(w, fmt.Sprintf("The identity provider returned the error %s", errorCode))
+		return
+	}
+
+	state := query.Get("state")
+	if cookieErr != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		writeOidcLoginError(w, "The login wasn't started in this browser")
+		return
+	}
+
+	params := db.TakeOidcAuthRequestParams{
+		State:    state,
+		Provider: name,
+	}
+	stored, err := a.queries.TakeOidcAuthRequest(r.Context(), params)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeOidcLoginError(w, "The login request is invalid or expired")
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	request := auth.OidcAuthRequest{
+		State:        params.State,
+		Nonce:        stored.Nonce,
+		CodeVerifier: stored.CodeVerifier,
+	}
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), request)
+	if err != nil {
+		log.Println("Error exchanging authorization code:", err)
+		writeOidcLoginError(w, "The identity provider didn't confirm the login")
+		return
+	}
+
+	// Another login can take the username between the check and the insert.
+	// The failed insert aborts the transaction, so the whole transaction is
+	// retried and picks another username.
+	var user db.User
+	for attempt := 1; ; attempt++ {
+		err = withTx(r.Context(), a.conn, a.queries, func(q *db.Queries) error {
+			user, err = a.oidcUser(r.Context(), q, name, identity)
+			return err
+		})
+		var pgErr *pgconn.PgError
+		if attempt < oidcUserAttempts && errors.As(err, &pgErr) && pgErr.ConstraintName == "user_username_key" {
+			continue
+		}
+		break
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, errOidcEmailMissing):
+			writeOidcLoginError(w, "The identity provider didn't share an email address")
+		case errors.Is(err, errOidcEmailTaken):
+			writeDuplicateUserError(w, "email")
+		default:
+			writeInternalServerError(w, err)
+		}
+		return
+	}
+
+	a.completeLogin(w, r, user, a.respondWithTokens)
+}
+
+// oidcStateCookie returns the cookie that holds the state of a login
+// request. A negative maxAge makes the browser delete it.
+func (a *AuthHandler) oidcStateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   a.sessions.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// oidcUser returns the user that is linked to the identity. On the first
+// login it links an existing user with the same verified email address or
+// creates a new user without a password.
+func (a *AuthHandler) oidcUser(ctx context.Context, q *db.Queries, provider string, identity auth.OidcIdentity) (db.User, error) {
+	user, err := q.GetUserByIdentity(ctx, db.GetUserByIdentityParams{
+		Provider: provider,
+		Subject:  identity.Subject,
+	})
+	if err == nil || !errors.Is(err, pgx.ErrNoRows) {
+		return user, err
+	}
+
+	if identity.Email == "" {
+		return db.User{}, errOidcEmailMissing
+	}
+
+	user, err = q.GetUserByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Linking by email is only safe if both sides confirmed that the
+		// address belongs to the user.
+		if !identity.EmailVerified || !user.EmailVerifiedAt.Valid {
+			return db.User{}, errOidcEmailTaken
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		user, err = a.createOidcUser(ctx, q, identity)
+		if err != nil {
+			return db.User{}, err
+		}
+	default:
+		return db.User{}, err
+	}
+
+	params := db.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  identity.Subject,
+	}
+	if err := q.CreateUserIdentity(ctx, params); err != nil {
+		return db.User{}, err
+	}
+
+	return user, nil
+}
+
+func (a *AuthHandler) createOidcUser(ctx context.Context, q *db.Queries, identity auth.OidcIdentity) (db.User, error) {
+	username, err := uniqueUsername(ctx, q, identity)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	user, err := q.CreateUser(ctx, db.CreateUserParams{
+		Username:     username,
+		Email:        identity.Email,
+		PasswordHash: auth.UnusablePassword,
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+
+	if identity.EmailVerified {
+		params := db.VerifyUserEmailParams{
+			ID:    user.ID,
+			Email: user.Email,
+		}
+		if _, err := q.VerifyUserEmail(ctx, params); err != nil {
+			return db.User{}, err
+		}
+		user, err = q.GetUser(ctx, user.ID)
+		if err != nil {
+			return db.User{}, err
+		}
+	}
+
+	if err := recordAudit(ctx, q, db.AuditActionCreate, auditEntityUser, user.ID, nil, newUserResponse(user)); err != nil {
+		return db.User{}, err
+	}
+	return user, nil
+}
+
+// uniqueUsername derives a free username from the preferred username or the
+// email address of the identity. A random suffix is added if it is taken.
+func uniqueUsername(ctx context.Context, q *db.Queries, identity auth.OidcIdentity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = usernameDisallowed.ReplaceAllString(base, "")
+	if len(base) > 15 {
+		base = base[:15]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	username := base
+	for i := 0; i < 10; i++ {
+		_, err := q.GetUserByUsername(ctx, username)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return username, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		username = fmt.Sprintf("%s-%04d", base, rand.IntN(10000))
+	}
+	return "", fmt.Errorf("no free username found for %s", base)
+}
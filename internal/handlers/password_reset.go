@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
+	"github.com/mderler/simple-go-backend/internal/mailer"
+)
+
+var (
+	errInvalidResetToken = errors.New("invalid password reset token")
+	errPersonalPassword  = errors.New("the password contains personal information")
+)
+
+// PasswordResetConfig controls the password reset links that get mailed to
+// users. The token is appended to URL as the query parameter token.
+type PasswordResetConfig struct {
+	URL string
+	TTL time.Duration
+}
+
+// @Summary Request a password reset
+// @Description Send a link to reset the password to the email address of the
+// @Description user. The response is the same whether a user with the email
+// @Description exists or not. Requesting a new link invalidates older ones.
+// @Tags Auth
+// @Accept json
+// @Param email body PasswordResetRequest true "Email address"
+// @Success 202 "Accepted"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Router /auth/password-reset [post]
+func (a *AuthHandler) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	request := &PasswordResetRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	// The reset is handled in the background so that neither the response
+	// time nor errors reveal whether a user with the email exists.
+	go a.startPasswordReset(context.WithoutCancel(r.Context()), request.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// startPasswordReset creates a reset token for the user with the email and
+// mails the link to them. Errors are logged, because the response was
+// already sent.
+func (a *AuthHandler) startPasswordReset(ctx context.Context, email string) {
+	user, err := a.queries.GetUserByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Println("Error requesting password reset:", err)
+		}
+		return
+	}
+
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		log.Println("Error requesting password reset:", err)
+		return
+	}
+
+	err = withTx(ctx, a.conn, a.queries, func(q *db.Queries) error {
+		if err := q.InvalidateUserPasswordResetTokens(ctx, user.ID); err != nil {
+			return err
+		}
+
+		params := db.CreatePasswordResetTokenParams{
+			UserID:     user.ID,
+			TokenHash:  tokenHash,
+			TtlSeconds: int32(a.resets.TTL.Seconds()),
+		}
+		return q.CreatePasswordResetToken(ctx, params)
+	})
+	if err != nil {
+		log.Println("Error requesting password reset:", err)
+		return
+	}
+
+	a.sendPasswordResetMail(user, token)
+}
+
+// @Summary Confirm a password reset
+// @Description Set a new password with the token of a password reset link.
+// @Description Every token can only be used once. All sessions of the user
+// @Description are logged out.
+// @Tags Auth
+// @Accept json
+// @Param reset body PasswordResetConfirmRequest true "Reset token and new password"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Invalid reset token"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/password-reset/confirm [post]
+func (a *AuthHandler) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	request := &PasswordResetConfirmRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	passwordHash, err := a.passwords.Hash(request.Password)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	err = withTx(r.Context(), a.conn, a.queries, func(q *db.Queries) error {
+		userID, err := q.UsePasswordResetToken(r.Context(), auth.HashOpaqueToken(request.Token))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errInvalidResetToken
+			}
+			return err
+		}
+
+		// The request only carries the token, so the password can't be
+		// compared with the username and email address before this point.
+		user, err := q.GetUser(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if auth.ContainsPersonalInfo(request.Password, user.Username, user.Email) {
+			return errPersonalPassword
+		}
+
+		params := db.UpdateUserPasswordParams{
+			ID:           userID,
+			PasswordHash: passwordHash,
+		}
+		if err := q.UpdateUserPassword(r.Context(), params); err != nil {
+			return err
+		}
+		if err := recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityUser, userID, nil, auditCredential{Credential: "password"}); err != nil {
+			return err
+		}
+
+		if err := q.InvalidateUserPasswordResetTokens(r.Context(), userID); err != nil {
+			return err
+		}
+		if err := q.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+			return err
+		}
+		return q.DeleteUserSessions(r.Context(), userID)
+	})
+	if err != nil {
+		if errors.Is(err, errInvalidResetToken) {
+			writeInvalidResetTokenError(w)
+			return
+		}
+		if errors.Is(err, errPersonalPassword) {
+			writeJson(w, newValidationErrorResponse("password_personal", "Password"), http.StatusUnprocessableEntity)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AuthHandler) sendPasswordResetMail(user db.User, token string) {
+	link, err := linkWithToken(a.resets.URL, token)
+	if err != nil {
+		log.Println("Error sending password reset mail:", err)
+		return
+	}
+
+	sendMail(a.mailer, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"someone requested to reset the password of your account. "+
+			"Open the following link to choose a new password:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't request this, you can ignore this email.\n",
+			user.Username, link, a.resets.TTL),
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// Policy decides whether the authenticated user may access a route. Policies
+// run after the Authenticator and the context middlewares of the route, so
+// they can rely on the values those put into the request context.
+type Policy func(r *http.Request) bool
+
+// authorize only lets a request through if at least one of the policies
+// allows it. All other requests get a 403.
+func authorize(policies ...Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, policy := range policies {
+				if policy(r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			writeForbiddenError(w)
+		})
+	}
+}
+
+// isAdmin allows administrators.
+func isAdmin(r *http.Request) bool {
+	return r.Context().Value(authUserRoleKey).(db.UserRole) == db.UserRoleAdmin
+}
+
+// isSelf allows the user that the {id} of a user route refers to.
+func isSelf(r *http.Request) bool {
+	return r.Context().Value(userIDKey).(int32) == r.Context().Value(authUserIDKey).(int32)
+}
+
+// isTodoCreator allows the creator of the todo that the {id} of a todo route
+// refers to.
+func isTodoCreator(r *http.Request) bool {
+	return r.Context().Value(todoAccessKey).(todoAccess).creator
+}
+
+// isTodoAssignee allows the users that are assigned to the todo that the {id}
+// of a todo route refers to.
+func isTodoAssignee(r *http.Request) bool {
+	return r.Context().Value(todoAccessKey).(todoAccess).assignee
+}
+
+// isTodoAncestorMember allows the creators and the assignees of the parents
+// of the todo that the {id} of a todo route refers to.
+func isTodoAncestorMember(r *http.Request) bool {
+	return r.Context().Value(todoAccessKey).(todoAccess).ancestorMember
+}
+
+// isLabelCreator allows the creator of the label that the {id} of a label
+// route refers to. Labels whose creator was deleted have none.
+func isLabelCreator(r *http.Request) bool {
+	creatorID := r.Context().Value(labelKey).(db.Label).CreatorID
+	return creatorID != nil && *creatorID == r.Context().Value(authUserIDKey).(int32)
+}
+
+// isCommentAuthor allows the author of the comment that the {commentId} of a
+// todo route refers to.
+func isCommentAuthor(r *http.Request) bool {
+	return r.Context().Value(commentKey).(db.TodoComment).AuthorID == r.Context().Value(authUserIDKey).(int32)
+}
+
+// isAttachmentUploader allows the uploader of the attachment that the
+// {attachmentId} of a todo route refers to.
+func isAttachmentUploader(r *http.Request) bool {
+	return r.Context().Value(attachmentKey).(db.TodoAttachment).UploaderID == r.Context().Value(authUserIDKey).(int32)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mderler/simple-go-backend/internal/ratelimit"
+)
+
+// RateLimiter applies the limits configured for each route name.
+type RateLimiter struct {
+	store  ratelimit.Store
+	limits map[string]ratelimit.Limit
+}
+
+func NewRateLimiter(store ratelimit.Store, limits map[string]ratelimit.Limit) *RateLimiter {
+	return &RateLimiter{store, limits}
+}
+
+// Limit returns a middleware that enforces the limit configured for the route.
+// Every client gets its own bucket, see clientKey. Requests are let through
+// if the store fails, so that an outage of the store doesn't take down the API.
+func (l *RateLimiter) Limit(route string) func(http.Handler) http.Handler {
+	limit, ok := l.limits[route]
+	if !ok {
+		log.Printf("No rate limit configured for route %s\n", route)
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := l.store.Take(r.Context(), route+":"+clientKey(r), limit)
+			if err != nil {
+				log.Println("Error taking rate limit token:", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+			if !result.Allowed {
+				writeRateLimitExceededError(w, ceilSeconds(result.RetryAfter))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the client of a request. Authenticated requests are
+// keyed by the API key or the user, all others by the IP address.
+func clientKey(r *http.Request) string {
+	if apiKeyID, ok := r.Context().Value(authApiKeyIDKey).(int32); ok {
+		return fmt.Sprintf("api-key:%d", apiKeyID)
+	}
+	if userID, ok := r.Context().Value(authUserIDKey).(int32); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the IP address of the client. Behind a proxy this is only
+// correct if the middleware.RealIP is enabled.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
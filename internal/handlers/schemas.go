@@ -1,22 +1,397 @@
 package handlers
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// UserRequest replaces a user. Users that change their own password have to
+// send their current one as well.
 type UserRequest struct {
-	Username string `json:"username" validate:"required,min=3,max=20"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8,max=255"`
+	Username        string `json:"username" validate:"required,min=3,max=20,excludes=@"`
+	Email           string `json:"email" validate:"required,email,max=255"`
+	Password        string `json:"password" validate:"required,max=255,password"`
+	CurrentPassword string `json:"currentPassword" validate:"max=255"`
+}
+
+// UserPatchRequest is filled with the current user before the merge patch is
+// applied. The password is only set if it was sent, users that change their
+// own password have to send their current one as well.
+type UserPatchRequest struct {
+	Username        string `json:"username" validate:"min=3,max=20,excludes=@"`
+	Email           string `json:"email" validate:"email,max=255"`
+	Password        string `json:"password" validate:"max=255,password"`
+	CurrentPassword string `json:"currentPassword" validate:"max=255"`
+}
+
+type UserRoleRequest struct {
+	Role db.UserRole `json:"role" validate:"required,oneof=admin member"`
+}
+
+type UserResponse struct {
+	ID            int32       `json:"id"`
+	Username      string      `json:"username"`
+	Email         string      `json:"email"`
+	EmailVerified bool        `json:"email_verified"`
+	Role          db.UserRole `json:"role"`
+}
+
+func newUserResponse(user db.User) UserResponse {
+	return UserResponse{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Role:          user.Role,
+	}
+}
+
+type LockoutResponse struct {
+	UserID      int32      `json:"user_id"`
+	Username    string     `json:"username"`
+	Failures    int32      `json:"failures"`
+	LockedUntil *time.Time `json:"locked_until"`
+}
+
+func newLockoutResponse(lockout db.ListLockedUsersRow) LockoutResponse {
+	return LockoutResponse{
+		UserID:      lockout.ID,
+		Username:    lockout.Username,
+		Failures:    lockout.Failures,
+		LockedUntil: timePtr(lockout.LockedUntil),
+	}
+}
+
+type ApiKeyCreateRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type ApiKeyResponse struct {
+	ID         int32      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+func newApiKeyResponse(apiKey db.ApiKey) ApiKeyResponse {
+	return ApiKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		CreatedAt:  apiKey.CreatedAt,
+		LastUsedAt: timePtr(apiKey.LastUsedAt),
+		RevokedAt:  timePtr(apiKey.RevokedAt),
+	}
+}
+
+// ApiKeyCreatedResponse is only returned once, directly after creating the
+// API key. The key can't be recovered later on.
+type ApiKeyCreatedResponse struct {
+	ApiKeyResponse
+	Key string `json:"key"`
+}
+
+// LoginRequest accepts either the username or the email address of the user
+// as username.
+type LoginRequest struct {
+	Username string `json:"username" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,max=255"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type" enums:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LoginTwoFactorRequest struct {
+	MfaToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+// MfaChallengeResponse is returned by the login instead of the tokens if the
+// user has two-factor authentication enabled.
+type MfaChallengeResponse struct {
+	MfaToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// SessionResponse describes the session behind the session cookie. The CSRF
+// token has to be sent in the X-CSRF-Token header of state-changing requests.
+type SessionResponse struct {
+	CsrfToken string    `json:"csrf_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,max=255,password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type TotpCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// TotpEnrollmentResponse is only returned once when enrolling. Neither the
+// secret nor the recovery codes can be retrieved later on.
+type TotpEnrollmentResponse struct {
+	Secret        string   `json:"secret"`
+	OtpauthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
+// TodoDetailResponse is a todo together with its creator, assignees, labels
+// and the offsets of its reminders in seconds before the due date.
+type TodoDetailResponse struct {
+	db.Todo
+	Creator   UserResponse   `json:"creator"`
+	Assignees []UserResponse `json:"assignees"`
+	Labels    []db.Label     `json:"labels"`
+	Reminders []int32        `json:"reminders"`
+	// Progress is null if the todo has no subtasks.
+	Progress *TodoProgressResponse `json:"progress"`
+}
+
+func newTodoDetailResponse(row db.GetTodoWithUsersRow) (TodoDetailResponse, error) {
+	response := TodoDetailResponse{
+		Todo:      row.Todo,
+		Creator:   newUserResponse(row.User),
+		Reminders: row.Reminders,
+		Progress:  newTodoProgressResponse(int32(row.SubtaskCount), int32(row.CompletedSubtaskCount)),
+	}
+	if err := json.Unmarshal(row.Assignees, &response.Assignees); err != nil {
+		return TodoDetailResponse{}, err
+	}
+	if err := json.Unmarshal(row.Labels, &response.Labels); err != nil {
+		return TodoDetailResponse{}, err
+	}
+	return response, nil
+}
+
+// TodoProgressResponse tells how many of the direct subtasks of a todo are
+// completed. Percent is rounded down.
+type TodoProgressResponse struct {
+	Subtasks  int32 `json:"subtasks"`
+	Completed int32 `json:"completed"`
+	Percent   int32 `json:"percent"`
+}
+
+func newTodoProgressResponse(subtasks int32, completed int32) *TodoProgressResponse {
+	if subtasks == 0 {
+		return nil
+	}
+	return &TodoProgressResponse{
+		Subtasks:  subtasks,
+		Completed: completed,
+		Percent:   completed * 100 / subtasks,
+	}
+}
+
+// TodoTreeResponse is a todo together with all of its subtasks, which are
+// sorted by their creation.
+type TodoTreeResponse struct {
+	db.Todo
+	Progress *TodoProgressResponse `json:"progress"`
+	Subtasks []TodoTreeResponse    `json:"subtasks"`
+}
+
+// newTodoTreeResponse builds the tree below the todo from the subtasks of
+// every todo in the tree.
+func newTodoTreeResponse(todo db.Todo, subtasks map[int32][]db.Todo) TodoTreeResponse {
+	response := TodoTreeResponse{
+		Todo:     todo,
+		Subtasks: make([]TodoTreeResponse, len(subtasks[todo.ID])),
+	}
+	var completed int32
+	for i, subtask := range subtasks[todo.ID] {
+		if subtask.Completed {
+			completed++
+		}
+		response.Subtasks[i] = newTodoTreeResponse(subtask, subtasks)
+	}
+	response.Progress = newTodoProgressResponse(int32(len(response.Subtasks)), completed)
+	return response
+}
+
+// TodoCreateRequest creates a todo. Reminders are given in seconds before the
+// due date and only fire if the todo has one. The priority defaults to none.
 type TodoCreateRequest struct {
-	Title       string `json:"title" validate:"required,min=1,max=255"`
-	Description string `json:"description" validate:"required,max=1000"`
-	CreatorID   int32  `json:"creatorId" validate:"required"`
+	Title       string          `json:"title" validate:"required,min=1,max=255"`
+	Description string          `json:"description" validate:"required,max=1000"`
+	DueAt       *time.Time      `json:"dueAt"`
+	Reminders   []int32         `json:"reminders" validate:"max=10,unique,dive,gte=0,lte=31536000"`
+	Priority    db.TodoPriority `json:"priority" validate:"omitempty,oneof=none low medium high urgent"`
+}
+
+func (t *TodoCreateRequest) priority() db.TodoPriority {
+	if t.Priority == "" {
+		return db.TodoPriorityNone
+	}
+	return t.Priority
 }
 
 type TodoUpdateRequest struct {
 	*TodoCreateRequest
-	Completed bool `json:"completed" validate:"required"`
+	Completed *bool `json:"completed" validate:"required"`
+}
+
+// TodoPatchRequest is filled with the current todo before the merge patch is
+// applied, so only the fields that were sent change.
+type TodoPatchRequest struct {
+	Title       string          `json:"title" validate:"min=1,max=255"`
+	Description string          `json:"description" validate:"max=1000"`
+	Completed   bool            `json:"completed"`
+	DueAt       *time.Time      `json:"dueAt"`
+	Reminders   []int32         `json:"reminders" validate:"max=10,unique,dive,gte=0,lte=31536000"`
+	Priority    db.TodoPriority `json:"priority" validate:"oneof=none low medium high urgent"`
 }
 
 type TodoAssignRequest struct {
 	UserID int32 `json:"userId" validate:"required"`
 }
+
+// TodoParentRequest moves a todo below another todo. A null parent turns the
+// todo into a top-level todo.
+type TodoParentRequest struct {
+	ParentID *int32 `json:"parentId"`
+}
+
+type TodoLabelRequest struct {
+	LabelID int32 `json:"labelId" validate:"required"`
+}
+
+// TodoAssigneesRequest replaces every assignee of a todo. An empty list
+// removes all of them.
+type TodoAssigneesRequest struct {
+	UserIDs []int32 `json:"userIds" validate:"required,max=100,unique"`
+}
+
+type TodoCommentRequest struct {
+	Body string `json:"body" validate:"required,min=1,max=5000"`
+}
+
+// TodoCommentResponse is a comment on a todo. EditedAt is null if the comment
+// was never edited.
+type TodoCommentResponse struct {
+	ID        int32      `json:"id"`
+	TodoID    int32      `json:"todo_id"`
+	AuthorID  int32      `json:"author_id"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"`
+}
+
+func newTodoCommentResponse(comment db.TodoComment) TodoCommentResponse {
+	return TodoCommentResponse{
+		ID:        comment.ID,
+		TodoID:    comment.TodoID,
+		AuthorID:  comment.AuthorID,
+		Body:      comment.Body,
+		CreatedAt: comment.CreatedAt,
+		EditedAt:  timePtr(comment.EditedAt),
+	}
+}
+
+// TodoCommentPageResponse holds a page of comments. NextCursor is null on the
+// last page.
+type TodoCommentPageResponse struct {
+	Comments   []TodoCommentResponse `json:"comments"`
+	NextCursor *int32                `json:"next_cursor"`
+}
+
+// TodoAttachmentResponse describes a file attached to a todo. The content
+// type is detected from the content of the file.
+type TodoAttachmentResponse struct {
+	ID          int32     `json:"id"`
+	TodoID      int32     `json:"todo_id"`
+	UploaderID  int32     `json:"uploader_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newTodoAttachmentResponse(attachment db.TodoAttachment) TodoAttachmentResponse {
+	return TodoAttachmentResponse{
+		ID:          attachment.ID,
+		TodoID:      attachment.TodoID,
+		UploaderID:  attachment.UploaderID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		SizeBytes:   attachment.SizeBytes,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+// LabelRequest creates or updates a label. Colors are hex codes like #1f77b4.
+type LabelRequest struct {
+	Name  string `json:"name" validate:"required,min=1,max=50"`
+	Color string `json:"color" validate:"required,hexcolor,len=7"`
+}
+
+// AuditEventResponse describes a mutation. Before and after hold the entity
+// as it was returned by the API and are null if it didn't exist.
+type AuditEventResponse struct {
+	ID         int32           `json:"id"`
+	ActorID    *int32          `json:"actor_id"`
+	Action     db.AuditAction  `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int32           `json:"entity_id"`
+	Before     json.RawMessage `json:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func newAuditEventResponse(event db.AuditEvent) AuditEventResponse {
+	response := AuditEventResponse{
+		ID:         event.ID,
+		Action:     event.Action,
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID,
+		Before:     json.RawMessage("null"),
+		After:      json.RawMessage("null"),
+		CreatedAt:  event.CreatedAt,
+	}
+	if event.ActorID.Valid {
+		response.ActorID = &event.ActorID.Int32
+	}
+	if event.BeforeState != nil {
+		response.Before = event.BeforeState
+	}
+	if event.AfterState != nil {
+		response.After = event.AfterState
+	}
+	return response
+}
+
+// AuditEventPageResponse holds a page of audit events. NextCursor is null on
+// the last page.
+type AuditEventPageResponse struct {
+	Events     []AuditEventResponse `json:"events"`
+	NextCursor *int32               `json:"next_cursor"`
+}
+
+func timePtr(t pgtype.Timestamp) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
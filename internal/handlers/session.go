@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+const csrfTokenHeader = "X-CSRF-Token"
+
+// SessionConfig controls the cookie of browser sessions. Secure should only
+// be turned off for local development over plain HTTP.
+type SessionConfig struct {
+	CookieName string
+	TTL        time.Duration
+	Secure     bool
+}
+
+// cookie returns the session cookie with the given value. A negative maxAge
+// makes the browser delete it.
+func (c SessionConfig) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     c.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// @Summary Start a session
+// @Description Check the credentials of a user and set an HttpOnly session
+// @Description cookie for browsers. The returned CSRF token has to be sent in
+// @Description the X-CSRF-Token header of every state-changing request that
+// @Description is authenticated by the cookie. A session that the browser
+// @Description already had is replaced. Users with two-factor authentication
+// @Description get an MFA token instead, which has to be exchanged at
+// @Description /auth/session/2fa.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginRequest true "User credentials"
+// @Success 200 {object} SessionResponse "Session started"
+// @Success 202 {object} MfaChallengeResponse "Second factor required"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Invalid credentials"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests or login locked"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/session [post]
+func (a *AuthHandler) createSession(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.checkPassword(w, r)
+	if !ok {
+		return
+	}
+
+	a.completeLogin(w, r, user, a.startSession)
+}
+
+// @Summary Start a session with a second factor
+// @Description Exchange the MFA token of a session login together with a TOTP
+// @Description code or a recovery code for a session cookie.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param credentials body LoginTwoFactorRequest true "MFA token and code"
+// @Success 200 {object} SessionResponse "Session started"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Invalid credentials"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests or login locked"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/session/2fa [post]
+func (a *AuthHandler) createSessionTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.checkSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	a.startSession(w, r, user)
+}
+
+// @Summary Get the current session
+// @Description Return the CSRF token of the session cookie, for example after
+// @Description a page reload.
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} SessionResponse "Current session"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/session [get]
+func (a *AuthHandler) getSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := r.Context().Value(authSessionKey).(db.GetActiveSessionRow)
+	if !ok {
+		writeUnauthorizedError(w, "The request wasn't authenticated with a session cookie")
+		return
+	}
+
+	response := SessionResponse{
+		CsrfToken: session.CsrfToken,
+		ExpiresAt: session.ExpiresAt,
+	}
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary End the session
+// @Description Delete the session of the cookie and clear the cookie. Ending a
+// @Description session that doesn't exist has no effect. An active session
+// @Description requires its CSRF token in the X-CSRF-Token header.
+// @Tags Auth
+// @Success 204 "No content"
+// @Failure 403 {object} ErrorResponse "Invalid CSRF token"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /auth/session [delete]
+func (a *AuthHandler) deleteSession(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(a.sessions.CookieName); err == nil {
+		if err := a.queries.DeleteSession(r.Context(), auth.HashOpaqueToken(cookie.Value)); err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+	}
+
+	http.SetCookie(w, a.sessions.cookie("", -1))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession creates a new session for the user and sets its cookie. Any
+// session the browser already had is deleted, so a session identifier that an
+// attacker planted before the login can't be used afterwards.
+func (a *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user db.User) {
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	csrfToken, err := auth.RandomID()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	if cookie, err := r.Cookie(a.sessions.CookieName); err == nil {
+		if err := a.queries.DeleteSession(r.Context(), auth.HashOpaqueToken(cookie.Value)); err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+	}
+
+	params := db.CreateSessionParams{
+		UserID:     user.ID,
+		TokenHash:  tokenHash,
+		CsrfToken:  csrfToken,
+		TtlSeconds: int32(a.sessions.TTL.Seconds()),
+	}
+	session, err := a.queries.CreateSession(r.Context(), params)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	http.SetCookie(w, a.sessions.cookie(token, int(a.sessions.TTL.Seconds())))
+
+	response := SessionResponse{
+		CsrfToken: session.CsrfToken,
+		ExpiresAt: session.ExpiresAt,
+	}
+	writeJson(w, response, http.StatusOK)
+}
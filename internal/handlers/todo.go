@@ -1,46 +1,144 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mderler/simple-go-backend/internal/db"
+	"github.com/mderler/simple-go-backend/internal/storage"
 )
 
+// todoPriorities are the priorities of todos from lowest to highest.
+var todoPriorities = []string{"none", "low", "medium", "high", "urgent"}
+
+var todoSorts = []string{"created", "priority"}
+
+// todoListQuery holds the filters and the sort order of a list of todos.
+type todoListQuery struct {
+	priority       db.NullTodoPriority
+	labelIDs       []int32
+	matchAllLabels bool
+	sort           string
+}
+
+// SubtaskConfig controls the nesting of todos. MaxDepth is the number of
+// levels of subtasks below a top-level todo. If AutoCompleteParents is set,
+// completing the last open subtask of a todo completes the todo as well.
+type SubtaskConfig struct {
+	MaxDepth            int32
+	AutoCompleteParents bool
+}
+
 type TodoHandler struct {
 	*chi.Mux
-	queries *db.Queries
+	conn        *pgxpool.Pool
+	queries     *db.Queries
+	blobs       storage.BlobStore
+	subtasks    SubtaskConfig
+	attachments AttachmentConfig
 }
 
-func NewTodoHandler(queries *db.Queries) *TodoHandler {
-	todoHandler := &TodoHandler{chi.NewRouter(), queries}
+func NewTodoHandler(conn *pgxpool.Pool, queries *db.Queries, blobs storage.BlobStore, subtasks SubtaskConfig, attachments AttachmentConfig, authn *Authenticator, limiter *RateLimiter) *TodoHandler {
+	todoHandler := &TodoHandler{chi.NewRouter(), conn, queries, blobs, subtasks, attachments}
 
-	todoHandler.Post("/", todoHandler.createTodo)
-	todoHandler.Get("/", todoHandler.getTodos)
+	todoHandler.Use(authn.Middleware)
+	todoHandler.Use(csrfProtect)
+	todoHandler.Use(limiter.Limit("todo"))
+
+	todoHandler.With(limiter.Limit("todo:create")).Post("/", todoHandler.createTodo)
+	todoHandler.With(authorize(isAdmin)).Get("/", todoHandler.getTodos)
 
 	todoHandler.Group(func(r chi.Router) {
 		r.Use(todoCtx)
-		r.Put("/{id}", todoHandler.updateTodo)
-		r.Delete("/{id}", todoHandler.deleteTodo)
-		r.Post("/{id}/assign", todoHandler.assignTodo)
+		r.Use(todoHandler.todoAccessCtx)
+		r.With(authorize(isTodoCreator, isTodoAssignee, isTodoAncestorMember)).Get("/{id}", todoHandler.getTodo)
+		r.With(authorize(isTodoCreator, isTodoAssignee)).Put("/{id}", todoHandler.updateTodo)
+		r.With(authorize(isTodoCreator, isTodoAssignee)).Patch("/{id}", todoHandler.patchTodo)
+		r.With(authorize(isTodoCreator)).Delete("/{id}", todoHandler.deleteTodo)
+		r.With(authorize(isTodoCreator)).Post("/{id}/assign", todoHandler.assignTodo)
+		r.With(authorize(isTodoCreator), assigneeCtx).Delete("/{id}/assign/{userId}", todoHandler.unassignTodo)
+		r.With(authorize(isTodoCreator, isTodoAssignee, isTodoAncestorMember)).Get("/{id}/assignees", todoHandler.getAssignees)
+		r.With(authorize(isTodoCreator)).Put("/{id}/assignees", todoHandler.replaceAssignees)
+		r.With(authorize(isTodoCreator, isTodoAssignee)).Post("/{id}/labels", todoHandler.attachLabel)
+		r.With(authorize(isTodoCreator, isTodoAssignee), todoLabelCtx).Delete("/{id}/labels/{labelId}", todoHandler.detachLabel)
+		r.With(authorize(isTodoCreator, isTodoAssignee), limiter.Limit("todo:create")).Post("/{id}/subtasks", todoHandler.createSubtask)
+		r.With(authorize(isTodoCreator, isTodoAssignee, isTodoAncestorMember)).Get("/{id}/subtasks", todoHandler.getSubtasks)
+		r.With(authorize(isTodoCreator, isTodoAssignee, isTodoAncestorMember)).Get("/{id}/tree", todoHandler.getTodoTree)
+		r.With(authorize(isTodoCreator)).Put("/{id}/parent", todoHandler.setTodoParent)
+		r.With(authorize(isTodoCreator, isTodoAssignee, isTodoAncestorMember)).Get("/{id}/comments", todoHandler.getComments)
+		r.With(authorize(isTodoCreator, isTodoAssignee)).Post("/{id}/comments", todoHandler.createComment)
+		r.With(commentCtx, todoHandler.commentLookupCtx, authorize(isCommentAuthor, isTodoCreator)).Put("/{id}/comments/{commentId}", todoHandler.updateComment)
+		r.With(commentCtx, todoHandler.commentLookupCtx, authorize(isCommentAuthor, isTodoCreator)).Delete("/{id}/comments/{commentId}", todoHandler.deleteComment)
+		r.With(authorize(isTodoCreator, isTodoAssignee, isTodoAncestorMember)).Get("/{id}/attachments", todoHandler.getAttachments)
+		r.With(authorize(isTodoCreator, isTodoAssignee), limiter.Limit("todo:attachment")).Post("/{id}/attachments", todoHandler.uploadAttachment)
+		r.With(attachmentCtx, todoHandler.attachmentLookupCtx, authorize(isTodoCreator, isTodoAssignee, isTodoAncestorMember)).Get("/{id}/attachments/{attachmentId}", todoHandler.downloadAttachment)
+		r.With(attachmentCtx, todoHandler.attachmentLookupCtx, authorize(isAttachmentUploader, isTodoCreator)).Delete("/{id}/attachments/{attachmentId}", todoHandler.deleteAttachment)
 	})
 	return todoHandler
 }
 
+// todoAccessCtx looks up how the authenticated user is related to the todo.
+// Users that neither created the todo nor are assigned to it or one of its
+// parents get a 404, so that the todos of other users aren't revealed.
+func (t *TodoHandler) todoAccessCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		todoID := r.Context().Value(todoIDKey).(int32)
+		userID := r.Context().Value(authUserIDKey).(int32)
+
+		params := db.GetTodoAccessParams{
+			ID:     todoID,
+			UserID: userID,
+		}
+		row, err := t.queries.GetTodoAccess(r.Context(), params)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeTodoNotFoundError(w, todoID)
+				return
+			}
+			writeInternalServerError(w, err)
+			return
+		}
+
+		access := newTodoAccess(row, userID)
+		if !access.visible() {
+			writeTodoNotFoundError(w, todoID)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), todoAccessKey, access)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // @Summary Create a new todo
-// @Description Create a new todo with the provided todo data.
+// @Description Create a new todo with the provided todo data. The authenticated user becomes its creator.
+// @Description Reminders are sent to the creator and the assignees the given number of seconds before the due date.
+// @Description Reminders of todos that are already overdue are not sent.
 // @Tags Todo
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param todo body TodoCreateRequest true "Todo data"
 // @Success 201 {object} db.Todo "Created todo"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
 // @Failure 422 {object} ValidationErrorResponse "Bad request"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /todo [post]
 func (t *TodoHandler) createTodo(w http.ResponseWriter, r *http.Request) {
+	creatorID := r.Context().Value(authUserIDKey).(int32)
+
 	todo := &TodoCreateRequest{}
 
 	if !decodeAndValidate(w, r, todo) {
@@ -50,13 +148,26 @@ func (t *TodoHandler) createTodo(w http.ResponseWriter, r *http.Request) {
 	params := db.CreateTodoParams{
 		Title:       todo.Title,
 		Description: todo.Description,
-		CreatorID:   todo.CreatorID,
+		CreatorID:   creatorID,
+		DueAt:       utcTime(todo.DueAt),
+		Priority:    todo.priority(),
 	}
-	dbTodo, err := t.queries.CreateTodo(r.Context(), params)
+	var dbTodo db.Todo
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		var err error
+		dbTodo, err = q.CreateTodo(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		if err := setTodoReminders(r.Context(), q, dbTodo.ID, todo.Reminders); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityTodo, dbTodo.ID, nil, dbTodo)
+	})
 	if err != nil {
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr); pgErr.Code == "23503" {
-			writeUserNotFoundError(w, todo.CreatorID)
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			writeUserNotFoundError(w, creatorID)
 			return
 		}
 		writeInternalServerError(w, err)
@@ -67,14 +178,34 @@ func (t *TodoHandler) createTodo(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get all todos
-// @Description Get the list of all todos.
+// @Description Get the list of all todos. Only administrators may do this.
 // @Tags Todo
 // @Produce json
+// @Security BearerAuth
+// @Param priority query string false "Only get todos with this priority" Enums(none, low, medium, high, urgent)
+// @Param label query []int false "Only get todos with these label IDs" collectionFormat(csv)
+// @Param label_match query string false "Whether todos need any or all of the labels (default any)" Enums(any, all)
+// @Param sort query string false "Sort by creation or by priority, newest first (default created)" Enums(created, priority)
 // @Success 200 {array} db.Todo "List of todos"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /todo [get]
 func (t *TodoHandler) getTodos(w http.ResponseWriter, r *http.Request) {
-	todos, err := t.queries.ListTodos(r.Context())
+	list, ok := parseTodoListQuery(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	params := db.ListTodosParams{
+		Priority:       list.priority,
+		LabelIds:       list.labelIDs,
+		MatchAllLabels: list.matchAllLabels,
+		Sort:           list.sort,
+	}
+	todos, err := t.queries.ListTodos(r.Context(), params)
 	if err != nil {
 		writeInternalServerError(w, err)
 		return
@@ -83,17 +214,112 @@ func (t *TodoHandler) getTodos(w http.ResponseWriter, r *http.Request) {
 	writeJson(w, todos, http.StatusOK)
 }
 
+// parseTodoListQuery parses the filters and the sort order of a list of
+// todos. Todos sorted by priority are sorted by their creation as well. Label
+// IDs can be repeated or separated by commas and todos need to have any or all
+// of them depending on label_match.
+func parseTodoListQuery(w http.ResponseWriter, query url.Values) (todoListQuery, bool) {
+	list := todoListQuery{labelIDs: []int32{}, sort: "created"}
+
+	if value := query.Get("priority"); value != "" {
+		if !slices.Contains(todoPriorities, value) {
+			writeInvalidQueryError(w, value, todoPriorities)
+			return todoListQuery{}, false
+		}
+		list.priority = db.NullTodoPriority{TodoPriority: db.TodoPriority(value), Valid: true}
+	}
+
+	for _, value := range query["label"] {
+		for _, labelID := range strings.Split(value, ",") {
+			id, err := strconv.ParseInt(labelID, 10, 32)
+			if err != nil {
+				writeInvalidQueryParamError(w, "label", labelID)
+				return todoListQuery{}, false
+			}
+			list.labelIDs = append(list.labelIDs, int32(id))
+		}
+	}
+	// The query counts the matching labels of a todo, so every ID may only
+	// appear once.
+	slices.Sort(list.labelIDs)
+	list.labelIDs = slices.Compact(list.labelIDs)
+
+	switch match := query.Get("label_match"); match {
+	case "", "any":
+	case "all":
+		list.matchAllLabels = true
+	default:
+		writeInvalidQueryError(w, match, []string{"any", "all"})
+		return todoListQuery{}, false
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if !slices.Contains(todoSorts, sort) {
+			writeInvalidQueryError(w, sort, todoSorts)
+			return todoListQuery{}, false
+		}
+		list.sort = sort
+	}
+
+	return list, true
+}
+
+// @Summary Get a todo
+// @Description Get a todo together with its creator and its assignees.
+// @Description The progress tells how many of its subtasks are completed.
+// @Description Only the creator and the assignees of the todo or one of its
+// @Description parents may do this.
+// @Tags Todo
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {object} TodoDetailResponse "Todo"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id} [get]
+func (t *TodoHandler) getTodo(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	row, err := t.queries.GetTodoWithUsers(r.Context(), todoID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeTodoNotFoundError(w, todoID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response, err := newTodoDetailResponse(row)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
 // @Summary Update a todo
 // @Description Update an existing todo with the provided todo data.
+// @Description Reminders that aren't part of the request are removed.
+// @Description Only the creator and the assignees of the todo may do this.
 // @Tags Todo
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path int true "Todo ID"
 // @Param todo body TodoUpdateRequest true "Todo data"
 // @Success 200 {object} db.Todo "Updated todo"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "Todo not found"
 // @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /todo/{id} [put]
 func (t *TodoHandler) updateTodo(w http.ResponseWriter, r *http.Request) {
@@ -109,13 +335,117 @@ func (t *TodoHandler) updateTodo(w http.ResponseWriter, r *http.Request) {
 		ID:          todoID,
 		Title:       todo.Title,
 		Description: todo.Description,
-		Completed:   todo.Completed,
+		Completed:   *todo.Completed,
+		DueAt:       utcTime(todo.DueAt),
+		Priority:    todo.priority(),
+	}
+	t.saveTodo(w, r, todoID, func(q *db.Queries, before db.Todo) (db.UpdateTodoParams, []int32, error) {
+		return params, todo.Reminders, nil
+	})
+}
+
+// @Summary Partially update a todo
+// @Description Update the fields of a todo that are part of the JSON Merge
+// @Description Patch (RFC 7396). Only the creator and the assignees of the
+// @Description todo may do this.
+// @Tags Todo
+// @Accept json,application/merge-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param todo body TodoPatchRequest true "Changed fields"
+// @Success 200 {object} db.Todo "Updated todo"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id} [patch]
+func (t *TodoHandler) patchTodo(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	patch, ok := decodeMergePatch(w, r, &TodoPatchRequest{})
+	if !ok {
+		return
 	}
-	dbTodo, err := t.queries.UpdateTodo(r.Context(), params)
+
+	t.saveTodo(w, r, todoID, func(q *db.Queries, before db.Todo) (db.UpdateTodoParams, []int32, error) {
+		reminders, err := q.ListTodoReminders(r.Context(), todoID)
+		if err != nil {
+			return db.UpdateTodoParams{}, nil, err
+		}
+
+		todo := &TodoPatchRequest{
+			Title:       before.Title,
+			Description: before.Description,
+			Completed:   before.Completed,
+			// A copy, applying the patch must not change the due date of
+			// before.
+			DueAt:     utcTime(before.DueAt),
+			Reminders: reminders,
+			Priority:  before.Priority,
+		}
+		if err := patch.applyTo(todo); err != nil {
+			return db.UpdateTodoParams{}, nil, err
+		}
+
+		params := db.UpdateTodoParams{
+			ID:          todoID,
+			Title:       todo.Title,
+			Description: todo.Description,
+			Completed:   todo.Completed,
+			DueAt:       utcTime(todo.DueAt),
+			Priority:    todo.Priority,
+		}
+		return params, todo.Reminders, nil
+	})
+}
+
+// saveTodo locks the todo, updates it and its reminders with what update
+// returns for the current state, records the change in the audit log and
+// writes the updated todo. Reminders that were already sent are sent again if
+// the due date changes. Completing a todo may complete its parents, see
+// SubtaskConfig.
+func (t *TodoHandler) saveTodo(w http.ResponseWriter, r *http.Request, todoID int32, update func(q *db.Queries, before db.Todo) (db.UpdateTodoParams, []int32, error)) {
+	var dbTodo db.Todo
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		before, err := q.GetTodoForUpdate(r.Context(), todoID)
+		if err != nil {
+			return err
+		}
+
+		params, reminders, err := update(q, before)
+		if err != nil {
+			return err
+		}
+
+		dbTodo, err = q.UpdateTodo(r.Context(), params)
+		if err != nil {
+			return err
+		}
+
+		if !sameTime(before.DueAt, dbTodo.DueAt) {
+			if err := q.ResetTodoReminders(r.Context(), todoID); err != nil {
+				return err
+			}
+		}
+		if err := setTodoReminders(r.Context(), q, todoID, reminders); err != nil {
+			return err
+		}
+		if err := recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityTodo, todoID, before, dbTodo); err != nil {
+			return err
+		}
+
+		if t.subtasks.AutoCompleteParents && dbTodo.Completed && !before.Completed {
+			return completeParents(r.Context(), q, dbTodo)
+		}
+		return nil
+	})
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr); pgErr.Code == "23503" {
-			writeUserNotFoundError(w, todo.CreatorID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeTodoNotFoundError(w, todoID)
 			return
 		}
 		writeInternalServerError(w, err)
@@ -125,47 +455,182 @@ func (t *TodoHandler) updateTodo(w http.ResponseWriter, r *http.Request) {
 	writeJson(w, dbTodo, http.StatusOK)
 }
 
+// setTodoReminders replaces the reminders of a todo. Reminders that are kept
+// keep whether they were already sent.
+func setTodoReminders(ctx context.Context, q *db.Queries, todoID int32, offsets []int32) error {
+	// A nil slice would be sent as NULL instead of an empty array.
+	if offsets == nil {
+		offsets = []int32{}
+	}
+
+	err := q.DeleteTodoRemindersNotIn(ctx, db.DeleteTodoRemindersNotInParams{
+		TodoID:  todoID,
+		Offsets: offsets,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.CreateTodoReminders(ctx, db.CreateTodoRemindersParams{
+		TodoID:  todoID,
+		Offsets: offsets,
+	})
+}
+
 // @Summary Delete a todo
-// @Description Delete an existing todo.
+// @Description Delete an existing todo together with all of its subtasks,
+// @Description including those of other users. Every deleted todo is recorded
+// @Description in the audit log together with its comments, attachments,
+// @Description assignments and labels. Only its creator may do this.
 // @Tags Todo
+// @Security BearerAuth
 // @Param id path int true "Todo ID"
 // @Success 204 "No content"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /todo/{id} [delete]
 func (t *TodoHandler) deleteTodo(w http.ResponseWriter, r *http.Request) {
 	todoID := r.Context().Value(todoIDKey).(int32)
 
-	affectedRows, err := t.queries.DeleteTodo(r.Context(), todoID)
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		// The subtasks are deleted by the cascade of their parent, so the tree
+		// may not change until each of them is recorded in the audit log.
+		if err := lockTodoTrees(r.Context(), q, todoID); err != nil {
+			return err
+		}
+
+		tree, err := q.GetTodoTree(r.Context(), todoID)
+		if err != nil {
+			return err
+		}
+		if len(tree) == 0 {
+			return pgx.ErrNoRows
+		}
+		todos := make([]db.Todo, len(tree))
+		for i, row := range tree {
+			todos[i] = row.Todo
+		}
+
+		if err := recordTodoDeletions(r.Context(), q, todoIDs(todos), pgtype.Int4{}); err != nil {
+			return err
+		}
+		_, err = q.DeleteTodo(r.Context(), todoID)
+		return err
+	})
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeTodoNotFoundError(w, todoID)
+			return
+		}
 		writeInternalServerError(w, err)
 		return
 	}
-	if affectedRows == 0 {
-		writeTodoNotFoundError(w, todoID)
-		return
-	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func todoIDs(todos []db.Todo) []int32 {
+	ids := make([]int32, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+	return ids
+}
+
+// recordTodoDeletions records an event for each of the todos and for
+// everything that a cascade deletes together with them: their comments,
+// attachments, assignments and labels. A valid userID adds the comments,
+// attachments and assignments of a deleted user on other todos. The todos
+// are locked, so that nothing changes between the snapshots and the cascade.
+// Their trees have to be locked by the caller.
+func recordTodoDeletions(ctx context.Context, q *db.Queries, ids []int32, userID pgtype.Int4) error {
+	todos, err := q.LockTodos(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	comments, err := q.ListTodoCommentsForDeletion(ctx, db.ListTodoCommentsForDeletionParams{
+		TodoIds:  ids,
+		AuthorID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		if err := recordAudit(ctx, q, db.AuditActionDelete, auditEntityComment, comment.ID, newTodoCommentResponse(comment), nil); err != nil {
+			return err
+		}
+	}
+
+	attachments, err := q.ListTodoAttachmentsForDeletion(ctx, db.ListTodoAttachmentsForDeletionParams{
+		TodoIds:    ids,
+		UploaderID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		if err := recordAudit(ctx, q, db.AuditActionDelete, auditEntityAttachment, attachment.ID, newTodoAttachmentResponse(attachment), nil); err != nil {
+			return err
+		}
+	}
+
+	assignments, err := q.ListTodoAssignmentsForDeletion(ctx, db.ListTodoAssignmentsForDeletionParams{
+		TodoIds: ids,
+		UserID:  userID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		if err := recordAudit(ctx, q, db.AuditActionUnassign, auditEntityTodo, assignment.TodoID, auditAssignment{UserID: assignment.UserID}, nil); err != nil {
+			return err
+		}
+	}
+
+	labels, err := q.ListTodoLabelsForDeletion(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, label := range labels {
+		if err := recordAudit(ctx, q, db.AuditActionUnassign, auditEntityTodo, label.TodoID, auditLabelAssignment{LabelID: label.LabelID}, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, todo := range todos {
+		if err := recordAudit(ctx, q, db.AuditActionDelete, auditEntityTodo, todo.ID, todo, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // @Summary Assign a user to a todo
-// @Description Assign a user to a todo.
+// @Description Assign a user to a todo. Only its creator may do this. Users
+// @Description can only be assigned after they verified their email address.
 // @Tags Todo
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path int true "Todo ID"
 // @Param todo body TodoAssignRequest true "User data"
 // @Success 201 "Created todo assignment"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "Todo or User not found"
-// @Failure 409 {object} ErrorResponse "Duplicate assignment"
+// @Failure 409 {object} ErrorResponse "Duplicate assignment or user not verified"
 // @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /todo/{id}/assign [post]
 func (t *TodoHandler) assignTodo(w http.ResponseWriter, r *http.Request) {
-	todoId := r.Context().Value(todoIDKey).(int32)
+	todoID := r.Context().Value(todoIDKey).(int32)
 
 	assign := &TodoAssignRequest{}
 
@@ -173,23 +638,11 @@ func (t *TodoHandler) assignTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	params := db.AssignUserToTodoParams{
-		TodoID: todoId,
-		UserID: assign.UserID,
-	}
-	_, err := t.queries.AssignUserToTodo(r.Context(), params)
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		return assignUser(r.Context(), q, todoID, assign.UserID)
+	})
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if !errors.As(err, &pgErr) {
-			writeInternalServerError(w, err)
-			return
-		}
-		switch pgErr.Code {
-		case "23503":
-			writeInvalidTodoAssignRequestError(w, pgErr, params.TodoID, assign.UserID)
-		case "23505":
-			writeDuplicateTodoAssignRequestError(w)
-		}
+		t.writeAssignError(w, r, todoID, err)
 		return
 	}
 
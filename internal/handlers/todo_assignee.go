@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+var errUserNotAssignable = errors.New("the user doesn't exist or hasn't verified their email address")
+
+// assigneeError ties an error of assigning a user to a todo to the user.
+type assigneeError struct {
+	userID int32
+	err    error
+}
+
+func (e *assigneeError) Error() string {
+	return fmt.Sprintf("assigning user %d: %v", e.userID, e.err)
+}
+
+func (e *assigneeError) Unwrap() error {
+	return e.err
+}
+
+// @Summary Get the assignees of a todo
+// @Description Get the users that are assigned to a todo. Only the creator
+// @Description and the assignees of the todo or one of its parents may do
+// @Description this.
+// @Tags Todo
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {array} UserResponse "List of assignees"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/assignees [get]
+func (t *TodoHandler) getAssignees(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	assignees, err := t.queries.ListTodoAssignees(r.Context(), todoID)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := make([]UserResponse, len(assignees))
+	for i, assignee := range assignees {
+		response[i] = newUserResponse(assignee)
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Replace the assignees of a todo
+// @Description Replace every assignee of a todo in one transaction. Users
+// @Description that stay assigned are kept as they are. Only the creator of
+// @Description the todo may do this and new assignees have to have verified
+// @Description their email address.
+// @Tags Todo
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param assignees body TodoAssigneesRequest true "IDs of the assignees"
+// @Success 200 {array} UserResponse "List of assignees"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo or User not found"
+// @Failure 409 {object} ErrorResponse "User not verified"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/assignees [put]
+func (t *TodoHandler) replaceAssignees(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	request := &TodoAssigneesRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	var assignees []db.User
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		current, err := q.ListTodoAssignees(r.Context(), todoID)
+		if err != nil {
+			return err
+		}
+
+		assigned := make(map[int32]bool, len(current))
+		for _, user := range current {
+			assigned[user.ID] = true
+			if !slices.Contains(request.UserIDs, user.ID) {
+				if err := unassignUser(r.Context(), q, todoID, user.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, userID := range request.UserIDs {
+			if !assigned[userID] {
+				if err := assignUser(r.Context(), q, todoID, userID); err != nil {
+					return err
+				}
+			}
+		}
+
+		assignees, err = q.ListTodoAssignees(r.Context(), todoID)
+		return err
+	})
+	if err != nil {
+		t.writeAssignError(w, r, todoID, err)
+		return
+	}
+
+	response := make([]UserResponse, len(assignees))
+	for i, assignee := range assignees {
+		response[i] = newUserResponse(assignee)
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Unassign a user from a todo
+// @Description Remove a user from the assignees of a todo. Only the creator
+// @Description of the todo may do this.
+// @Tags Todo
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param userId path int true "User ID"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found or user not assigned"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/assign/{userId} [delete]
+func (t *TodoHandler) unassignTodo(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+	userID := r.Context().Value(assigneeIDKey).(int32)
+
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		return unassignUser(r.Context(), q, todoID, userID)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeTodoAssignmentNotFoundError(w, userID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// assignUser assigns the user to the todo and records it in the audit log.
+// The returned error is an *assigneeError.
+func assignUser(ctx context.Context, q *db.Queries, todoID int32, userID int32) error {
+	params := db.AssignUserToTodoParams{
+		TodoID: todoID,
+		UserID: userID,
+	}
+	rows, err := q.AssignUserToTodo(ctx, params)
+	if err == nil && rows == 0 {
+		err = errUserNotAssignable
+	}
+	if err == nil {
+		err = recordAudit(ctx, q, db.AuditActionAssign, auditEntityTodo, todoID, nil, auditAssignment{UserID: userID})
+	}
+	if err != nil {
+		return &assigneeError{userID, err}
+	}
+	return nil
+}
+
+// unassignUser removes the user from the todo and records it in the audit
+// log. It returns pgx.ErrNoRows if the user wasn't assigned.
+func unassignUser(ctx context.Context, q *db.Queries, todoID int32, userID int32) error {
+	params := db.UnassignUserFromTodoParams{
+		TodoID: todoID,
+		UserID: userID,
+	}
+	rows, err := q.UnassignUserFromTodo(ctx, params)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return recordAudit(ctx, q, db.AuditActionUnassign, auditEntityTodo, todoID, auditAssignment{UserID: userID}, nil)
+}
+
+// writeAssignError writes the error of assigning users to a todo. Users that
+// couldn't be assigned are looked up to tell apart missing users from users
+// that haven't verified their email address yet.
+func (t *TodoHandler) writeAssignError(w http.ResponseWriter, r *http.Request, todoID int32, err error) {
+	var assigneeErr *assigneeError
+	if !errors.As(err, &assigneeErr) {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, errUserNotAssignable):
+		if _, err := t.queries.GetUser(r.Context(), assigneeErr.userID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeUserNotFoundError(w, assigneeErr.userID)
+				return
+			}
+			writeInternalServerError(w, err)
+			return
+		}
+		writeUserNotVerifiedError(w, assigneeErr.userID)
+	case errors.As(err, &pgErr) && pgErr.Code == "23503":
+		writeInvalidTodoAssignRequestError(w, pgErr, todoID, assigneeErr.userID)
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		writeDuplicateTodoAssignRequestError(w)
+	default:
+		writeInternalServerError(w, err)
+	}
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mderler/simple-go-backend/internal/db"
+	"github.com/mderler/simple-go-backend/internal/storage"
+)
+
+// multipartOverhead is the room that the body of an upload gets on top of
+// the file for the boundaries and headers of the multipart form.
+const multipartOverhead = 64 << 10
+
+// AttachmentConfig limits the files that can be attached to todos. MaxSize
+// is given in bytes and AllowedTypes holds media types like image/png.
+type AttachmentConfig struct {
+	MaxSize      int64
+	AllowedTypes []string
+}
+
+// attachmentLookupCtx loads the attachment that the {attachmentId} of the
+// route refers to. Attachments of other todos aren't found.
+func (t *TodoHandler) attachmentLookupCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		todoID := r.Context().Value(todoIDKey).(int32)
+		attachmentID := r.Context().Value(attachmentIDKey).(int32)
+
+		params := db.GetTodoAttachmentParams{
+			ID:     attachmentID,
+			TodoID: todoID,
+		}
+		attachment, err := t.queries.GetTodoAttachment(r.Context(), params)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeAttachmentNotFoundError(w, attachmentID)
+				return
+			}
+			writeInternalServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), attachmentKey, attachment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// @Summary Get the attachments of a todo
+// @Description Get the files that are attached to a todo, oldest first. Only
+// @Description the creator and the assignees of the todo or one of its
+// @Description parents may do this.
+// @Tags Todo
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {array} TodoAttachmentResponse "List of attachments"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/attachments [get]
+func (t *TodoHandler) getAttachments(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	attachments, err := t.queries.ListTodoAttachments(r.Context(), todoID)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := make([]TodoAttachmentResponse, len(attachments))
+	for i, attachment := range attachments {
+		response[i] = newTodoAttachmentResponse(attachment)
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Attach a file to a todo
+// @Description Upload a file as the form field file of a multipart form. The
+// @Description content type is detected from the content of the file and has
+// @Description to be one of the allowed types. Only the creator and the
+// @Description assignees of the todo may do this.
+// @Tags Todo
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param file formData file true "File"
+// @Success 201 {object} TodoAttachmentResponse "Created attachment"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 413 {object} ErrorResponse "File too large"
+// @Failure 415 {object} ErrorResponse "Unsupported file type"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/attachments [post]
+func (t *TodoHandler) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+	uploaderID := r.Context().Value(authUserIDKey).(int32)
+
+	r.Body = http.MaxBytesReader(w, r.Body, t.attachments.MaxSize+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeInvalidAttachmentError(w, err)
+		return
+	}
+
+	part, err := filePart(reader)
+	if err != nil {
+		t.writeUploadError(w, err)
+		return
+	}
+	defer part.Close()
+
+	fileName := part.FileName()
+	if fileName == "" || utf8.RuneCountInString(fileName) > 255 {
+		writeInvalidAttachmentError(w, fmt.Errorf("the file name %q is not valid", fileName))
+		return
+	}
+
+	// The file is kept in memory, so that its size is known before it is
+	// stored and it can be checked before anything is written.
+	content, err := io.ReadAll(io.LimitReader(part, t.attachments.MaxSize+1))
+	if err != nil {
+		t.writeUploadError(w, err)
+		return
+	}
+	if int64(len(content)) > t.attachments.MaxSize {
+		writeAttachmentTooLargeError(w, t.attachments.MaxSize)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(content))
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	if !slices.Contains(t.attachments.AllowedTypes, contentType) {
+		writeUnsupportedAttachmentError(w, contentType, t.attachments.AllowedTypes)
+		return
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	// The blob is queued for deletion until its attachment exists, so that
+	// it doesn't leak if the upload fails or the process dies in between.
+	if err := t.queries.QueueOrphanedBlob(r.Context(), key); err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	if err := t.blobs.Put(r.Context(), key, bytes.NewReader(content), int64(len(content)), contentType); err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	params := db.CreateTodoAttachmentParams{
+		TodoID:      todoID,
+		UploaderID:  uploaderID,
+		FileName:    fileName,
+		ContentType: contentType,
+		SizeBytes:   int64(len(content)),
+		StorageKey:  key,
+	}
+	var response TodoAttachmentResponse
+	err = withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		attachment, err := q.CreateTodoAttachment(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		if err := q.DeleteOrphanedBlob(r.Context(), key); err != nil {
+			return err
+		}
+		response = newTodoAttachmentResponse(attachment)
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityAttachment, attachment.ID, nil, response)
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "todo_attachment_todo_id_fkey":
+			writeTodoNotFoundError(w, todoID)
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
+			writeUserNotFoundError(w, uploaderID)
+		default:
+			writeInternalServerError(w, err)
+		}
+		return
+	}
+
+	writeJson(w, response, http.StatusCreated)
+}
+
+// filePart skips to the file form field of the multipart form.
+func filePart(reader *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("the form has no file field")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+// writeUploadError writes the error of reading an upload. Bodies that exceed
+// the limit of http.MaxBytesReader are too large, other errors come from
+// malformed forms.
+func (t *TodoHandler) writeUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeAttachmentTooLargeError(w, t.attachments.MaxSize)
+		return
+	}
+	writeInvalidAttachmentError(w, err)
+}
+
+// newStorageKey returns a random key for the blob of an attachment.
+func newStorageKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// @Summary Download an attachment
+// @Description Download the file of an attachment. Only the creator and the
+// @Description assignees of the todo or one of its parents may do this.
+// @Tags Todo
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 200 {file} file "Content of the file"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo or attachment not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/attachments/{attachmentId} [get]
+func (t *TodoHandler) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment := r.Context().Value(attachmentKey).(db.TodoAttachment)
+
+	blob, err := t.blobs.Get(r.Context(), attachment.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeAttachmentNotFoundError(w, attachment.ID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	// Browsers shouldn't render uploaded files in the context of the API.
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, blob); err != nil {
+		log.Println("Error sending attachment:", err)
+	}
+}
+
+// @Summary Delete an attachment
+// @Description Delete an attachment. Its file is removed from the storage in
+// @Description the background. Only the uploader of the attachment and the
+// @Description creator of the todo may do this.
+// @Tags Todo
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param attachmentId path int true "Attachment ID"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo or attachment not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/attachments/{attachmentId} [delete]
+func (t *TodoHandler) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment := r.Context().Value(attachmentKey).(db.TodoAttachment)
+
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		before, err := q.GetTodoAttachment(r.Context(), db.GetTodoAttachmentParams{ID: attachment.ID, TodoID: attachment.TodoID})
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.DeleteTodoAttachment(r.Context(), attachment.ID); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionDelete, auditEntityAttachment, attachment.ID, newTodoAttachmentResponse(before), nil)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeAttachmentNotFoundError(w, attachment.ID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+const (
+	defaultCommentPageSize = 50
+	maxCommentPageSize     = 100
+)
+
+// commentLookupCtx loads the comment that the {commentId} of the route refers
+// to. Comments of other todos and deleted comments aren't found.
+func (t *TodoHandler) commentLookupCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		todoID := r.Context().Value(todoIDKey).(int32)
+		commentID := r.Context().Value(commentIDKey).(int32)
+
+		params := db.GetTodoCommentParams{
+			ID:     commentID,
+			TodoID: todoID,
+		}
+		comment, err := t.queries.GetTodoComment(r.Context(), params)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeCommentNotFoundError(w, commentID)
+				return
+			}
+			writeInternalServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), commentKey, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// @Summary Get the comments of a todo
+// @Description Get the comments of a todo, oldest first. Deleted comments are
+// @Description left out. Pass the next_cursor of a page as cursor to get the
+// @Description next page. Only the creator and the assignees of the todo or
+// @Description one of its parents may do this.
+// @Tags Todo
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param cursor query int false "Cursor of the page"
+// @Param limit query int false "Number of comments per page (1-100, default 50)"
+// @Success 200 {object} TodoCommentPageResponse "Page of comments"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/comments [get]
+func (t *TodoHandler) getComments(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+	query := r.URL.Query()
+
+	params := db.ListTodoCommentsParams{TodoID: todoID}
+	var ok bool
+
+	if params.Cursor, ok = parseInt4Query(w, query, "cursor"); !ok {
+		return
+	}
+	if params.PageSize, ok = parsePageSizeQuery(w, query, defaultCommentPageSize, maxCommentPageSize); !ok {
+		return
+	}
+
+	// One more comment than requested tells whether there is another page.
+	pageSize := params.PageSize
+	params.PageSize++
+	comments, err := t.queries.ListTodoComments(r.Context(), params)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := TodoCommentPageResponse{Comments: []TodoCommentResponse{}}
+	if len(comments) > int(pageSize) {
+		comments = comments[:pageSize]
+		response.NextCursor = &comments[len(comments)-1].ID
+	}
+	for _, comment := range comments {
+		response.Comments = append(response.Comments, newTodoCommentResponse(comment))
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Comment on a todo
+// @Description Add a comment to a todo. The authenticated user becomes its
+// @Description author. Only the creator and the assignees of the todo may do
+// @Description this.
+// @Tags Todo
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param comment body TodoCommentRequest true "Comment data"
+// @Success 201 {object} TodoCommentResponse "Created comment"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/comments [post]
+func (t *TodoHandler) createComment(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+	authorID := r.Context().Value(authUserIDKey).(int32)
+
+	request := &TodoCommentRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	params := db.CreateTodoCommentParams{
+		TodoID:   todoID,
+		AuthorID: authorID,
+		Body:     request.Body,
+	}
+	var response TodoCommentResponse
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		comment, err := q.CreateTodoComment(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		response = newTodoCommentResponse(comment)
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityComment, comment.ID, nil, response)
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "todo_comment_todo_id_fkey":
+			writeTodoNotFoundError(w, todoID)
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
+			writeUserNotFoundError(w, authorID)
+		default:
+			writeInternalServerError(w, err)
+		}
+		return
+	}
+
+	writeJson(w, response, http.StatusCreated)
+}
+
+// @Summary Edit a comment
+// @Description Replace the body of a comment and mark it as edited. Only the
+// @Description author of the comment and the creator of the todo may do this.
+// @Tags Todo
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param commentId path int true "Comment ID"
+// @Param comment body TodoCommentRequest true "Comment data"
+// @Success 200 {object} TodoCommentResponse "Updated comment"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo or comment not found"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/comments/{commentId} [put]
+func (t *TodoHandler) updateComment(w http.ResponseWriter, r *http.Request) {
+	comment := r.Context().Value(commentKey).(db.TodoComment)
+
+	request := &TodoCommentRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	params := db.UpdateTodoCommentParams{
+		Body: request.Body,
+		ID:   comment.ID,
+	}
+	var response TodoCommentResponse
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		before, err := q.GetTodoComment(r.Context(), db.GetTodoCommentParams{ID: comment.ID, TodoID: comment.TodoID})
+		if err != nil {
+			return err
+		}
+
+		updated, err := q.UpdateTodoComment(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		response = newTodoCommentResponse(updated)
+		return recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityComment, comment.ID, newTodoCommentResponse(before), response)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeCommentNotFoundError(w, comment.ID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, response, http.StatusOK)
+}
+
+// @Summary Delete a comment
+// @Description Delete a comment. The comment is kept for the audit log but no
+// @Description longer listed. Only the author of the comment and the creator
+// @Description of the todo may do this.
+// @Tags Todo
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param commentId path int true "Comment ID"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo or comment not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/comments/{commentId} [delete]
+func (t *TodoHandler) deleteComment(w http.ResponseWriter, r *http.Request) {
+	comment := r.Context().Value(commentKey).(db.TodoComment)
+
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		before, err := q.GetTodoComment(r.Context(), db.GetTodoCommentParams{ID: comment.ID, TodoID: comment.TodoID})
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.DeleteTodoComment(r.Context(), comment.ID); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionDelete, auditEntityComment, comment.ID, newTodoCommentResponse(before), nil)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeCommentNotFoundError(w, comment.ID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// @Summary Attach a label to a todo
+// @Description Attach a label to a todo. Only the creator and the assignees
+// @Description of the todo may do this.
+// @Tags Todo
+// @Accept json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param label body TodoLabelRequest true "Label data"
+// @Success 201 "Attached label"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo or label not found"
+// @Failure 409 {object} ErrorResponse "Label already attached"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/labels [post]
+func (t *TodoHandler) attachLabel(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	request := &TodoLabelRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	params := db.AttachLabelToTodoParams{
+		TodoID:  todoID,
+		LabelID: request.LabelID,
+	}
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		if err := q.AttachLabelToTodo(r.Context(), params); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionAssign, auditEntityTodo, todoID, nil, auditLabelAssignment{LabelID: request.LabelID})
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "todo_label_label_id_fkey":
+			writeLabelNotFoundError(w, request.LabelID)
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "todo_label_todo_id_fkey":
+			writeTodoNotFoundError(w, todoID)
+		case errors.As(err, &pgErr) && pgErr.Code == "23505":
+			writeDuplicateTodoLabelError(w, request.LabelID)
+		default:
+			writeInternalServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// @Summary Detach a label from a todo
+// @Description Remove a label from a todo. Only the creator and the assignees
+// @Description of the todo may do this.
+// @Tags Todo
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param labelId path int true "Label ID"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found or label not attached"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/labels/{labelId} [delete]
+func (t *TodoHandler) detachLabel(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+	labelID := r.Context().Value(labelIDKey).(int32)
+
+	params := db.DetachLabelFromTodoParams{
+		TodoID:  todoID,
+		LabelID: labelID,
+	}
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		rows, err := q.DetachLabelFromTodo(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return pgx.ErrNoRows
+		}
+
+		return recordAudit(r.Context(), q, db.AuditActionUnassign, auditEntityTodo, todoID, auditLabelAssignment{LabelID: labelID}, nil)
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeTodoLabelNotFoundError(w, labelID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
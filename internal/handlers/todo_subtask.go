@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+var (
+	errTodoParentNotFound  = errors.New("the parent todo doesn't exist or isn't accessible")
+	errTodoParentForbidden = errors.New("the parent todo can only be read")
+	errTodoCycle           = errors.New("the parent todo is the todo itself or one of its subtasks")
+	errTodoDepthExceeded   = errors.New("the subtasks would be nested too deeply")
+)
+
+// @Summary Create a subtask
+// @Description Create a new todo below another todo. The authenticated user
+// @Description becomes its creator. Only the creator and the assignees of the
+// @Description parent may do this. Users that can only read the parent through
+// @Description one of its own parents get a 403.
+// @Tags Todo
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Parent todo ID"
+// @Param todo body TodoCreateRequest true "Todo data"
+// @Success 201 {object} db.Todo "Created subtask"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 409 {object} ErrorResponse "Subtasks nested too deeply"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/subtasks [post]
+func (t *TodoHandler) createSubtask(w http.ResponseWriter, r *http.Request) {
+	parentID := r.Context().Value(todoIDKey).(int32)
+	creatorID := r.Context().Value(authUserIDKey).(int32)
+
+	todo := &TodoCreateRequest{}
+
+	if !decodeAndValidate(w, r, todo) {
+		return
+	}
+
+	params := db.CreateTodoParams{
+		Title:       todo.Title,
+		Description: todo.Description,
+		CreatorID:   creatorID,
+		DueAt:       utcTime(todo.DueAt),
+		Priority:    todo.priority(),
+		ParentID:    &parentID,
+	}
+	var dbTodo db.Todo
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		if err := lockTodoTrees(r.Context(), q, parentID); err != nil {
+			return err
+		}
+
+		// The parent and each of its ancestors add a level above the subtask.
+		ancestors, err := q.ListTodoAncestorIDs(r.Context(), parentID)
+		if err != nil {
+			return err
+		}
+		if len(ancestors) == 0 {
+			return pgx.ErrNoRows
+		}
+		if int32(len(ancestors)) > t.subtasks.MaxDepth {
+			return errTodoDepthExceeded
+		}
+
+		dbTodo, err = q.CreateTodo(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		if err := setTodoReminders(r.Context(), q, dbTodo.ID, todo.Reminders); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityTodo, dbTodo.ID, nil, dbTodo)
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			writeTodoNotFoundError(w, parentID)
+		case errors.Is(err, errTodoDepthExceeded):
+			writeTodoDepthExceededError(w, t.subtasks.MaxDepth)
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "todo_parent_id_fkey":
+			writeTodoNotFoundError(w, parentID)
+		case errors.As(err, &pgErr) && pgErr.Code == "23503":
+			writeUserNotFoundError(w, creatorID)
+		default:
+			writeInternalServerError(w, err)
+		}
+		return
+	}
+
+	writeJson(w, dbTodo, http.StatusCreated)
+}
+
+// @Summary Get the subtasks of a todo
+// @Description Get the direct subtasks of a todo, sorted by their creation.
+// @Description Only the creator and the assignees of the todo or one of its
+// @Description parents may do this.
+// @Tags Todo
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {array} db.Todo "List of subtasks"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/subtasks [get]
+func (t *TodoHandler) getSubtasks(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	subtasks, err := t.queries.ListSubtasks(r.Context(), &todoID)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, subtasks, http.StatusOK)
+}
+
+// @Summary Get the tree of a todo
+// @Description Get a todo together with all of its subtasks, nested as deep as
+// @Description they go. Every todo in the tree reports the progress of its
+// @Description direct subtasks. Only the creator and the assignees of the todo
+// @Description or one of its parents may do this.
+// @Tags Todo
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Success 200 {object} TodoTreeResponse "Todo tree"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/tree [get]
+func (t *TodoHandler) getTodoTree(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+
+	rows, err := t.queries.GetTodoTree(r.Context(), todoID)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	if len(rows) == 0 {
+		writeTodoNotFoundError(w, todoID)
+		return
+	}
+
+	// The rows are sorted by depth, so the todo itself comes first.
+	subtasks := make(map[int32][]db.Todo)
+	for _, row := range rows[1:] {
+		subtasks[*row.Todo.ParentID] = append(subtasks[*row.Todo.ParentID], row.Todo)
+	}
+
+	writeJson(w, newTodoTreeResponse(rows[0].Todo, subtasks), http.StatusOK)
+}
+
+// @Summary Move a todo
+// @Description Move a todo together with its subtasks below another todo, or
+// @Description make it a top-level todo with a null parent. A todo can't be
+// @Description moved below itself or one of its subtasks. Only the creator of
+// @Description the todo may do this. Like for creating a subtask, they have to
+// @Description be the creator or an assignee of the new parent. Users that can
+// @Description only read the new parent through one of its own parents get a
+// @Description 403, users that can't see it a 404.
+// @Tags Todo
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Todo ID"
+// @Param parent body TodoParentRequest true "Parent todo ID"
+// @Success 200 {object} db.Todo "Moved todo"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Todo or parent not found"
+// @Failure 409 {object} ErrorResponse "Cycle or subtasks nested too deeply"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /todo/{id}/parent [put]
+func (t *TodoHandler) setTodoParent(w http.ResponseWriter, r *http.Request) {
+	todoID := r.Context().Value(todoIDKey).(int32)
+	userID := r.Context().Value(authUserIDKey).(int32)
+
+	request := &TodoParentRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	params := db.SetTodoParentParams{
+		ParentID: request.ParentID,
+		ID:       todoID,
+	}
+	var dbTodo db.Todo
+	err := withTx(r.Context(), t.conn, t.queries, func(q *db.Queries) error {
+		// Moving two todos at once could create a cycle that neither of the
+		// checks sees, so the trees of the todo and the new parent are locked.
+		lockIDs := []int32{todoID}
+		if request.ParentID != nil {
+			lockIDs = append(lockIDs, *request.ParentID)
+		}
+		if err := lockTodoTrees(r.Context(), q, lockIDs...); err != nil {
+			return err
+		}
+
+		before, err := q.GetTodo(r.Context(), todoID)
+		if err != nil {
+			return err
+		}
+
+		if request.ParentID != nil {
+			if err := t.checkTodoParent(r.Context(), q, todoID, *request.ParentID, userID); err != nil {
+				return err
+			}
+		}
+
+		dbTodo, err = q.SetTodoParent(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityTodo, todoID, before, dbTodo)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			writeTodoNotFoundError(w, todoID)
+		case errors.Is(err, errTodoParentNotFound):
+			writeTodoNotFoundError(w, *request.ParentID)
+		case errors.Is(err, errTodoParentForbidden):
+			writeForbiddenError(w)
+		case errors.Is(err, errTodoCycle):
+			writeTodoCycleError(w, todoID, *request.ParentID)
+		case errors.Is(err, errTodoDepthExceeded):
+			writeTodoDepthExceededError(w, t.subtasks.MaxDepth)
+		default:
+			writeInternalServerError(w, err)
+		}
+		return
+	}
+
+	writeJson(w, dbTodo, http.StatusOK)
+}
+
+// checkTodoParent checks whether the todo may be moved below the parent. The
+// user needs the same access to the parent as for creating a subtask below
+// it, the parent may not be part of the tree of the todo and the deepest
+// subtask of the todo may not exceed the max depth.
+func (t *TodoHandler) checkTodoParent(ctx context.Context, q *db.Queries, todoID int32, parentID int32, userID int32) error {
+	row, err := q.GetTodoAccess(ctx, db.GetTodoAccessParams{
+		ID:     parentID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errTodoParentNotFound
+		}
+		return err
+	}
+	access := newTodoAccess(row, userID)
+	if !access.visible() {
+		return errTodoParentNotFound
+	}
+	if !access.creator && !access.assignee {
+		return errTodoParentForbidden
+	}
+
+	ancestors, err := q.ListTodoAncestorIDs(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(ancestors, todoID) {
+		return errTodoCycle
+	}
+
+	tree, err := q.GetTodoTree(ctx, todoID)
+	if err != nil {
+		return err
+	}
+	if len(tree) == 0 {
+		return pgx.ErrNoRows
+	}
+	if int32(len(ancestors))+tree[len(tree)-1].Depth > t.subtasks.MaxDepth {
+		return errTodoDepthExceeded
+	}
+	return nil
+}
+
+// lockTodoTrees locks the trees that contain the todos until the transaction
+// ends. Every change to the parents of todos locks the trees it touches
+// first, so the structure of a locked tree can't change in the meantime.
+// Locks are taken in the order of the root IDs to avoid deadlocks.
+func lockTodoTrees(ctx context.Context, q *db.Queries, todoIDs ...int32) error {
+	var locked []int32
+	for {
+		roots, err := q.ListTodoRootIDs(ctx, todoIDs)
+		if err != nil {
+			return err
+		}
+
+		// A root could have been moved below another todo while this waited
+		// for its lock, so the roots are looked up again until all of them
+		// are locked.
+		var missing []int32
+		for _, root := range roots {
+			if !slices.Contains(locked, root) {
+				missing = append(missing, root)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		if err := q.LockTodoTrees(ctx, missing); err != nil {
+			return err
+		}
+		locked = append(locked, missing...)
+	}
+}
+
+// completeParents walks up the parents of a completed todo and completes
+// every parent whose subtasks are all completed. It stops at the first parent
+// that still has open subtasks.
+func completeParents(ctx context.Context, q *db.Queries, todo db.Todo) error {
+	for parentID := todo.ParentID; parentID != nil; {
+		before, err := q.GetTodo(ctx, *parentID)
+		if err != nil {
+			return err
+		}
+
+		parent, err := q.CompleteTodoIfSubtasksDone(ctx, *parentID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		if err := recordAudit(ctx, q, db.AuditActionUpdate, auditEntityTodo, parent.ID, before, parent); err != nil {
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
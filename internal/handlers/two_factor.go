@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mderler/simple-go-backend/internal/auth"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+var errTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+// @Summary Enroll in two-factor authentication
+// @Description Create a new TOTP secret and a set of recovery codes for the
+// @Description user. Two-factor authentication is only enabled after a code
+// @Description was confirmed with the verify endpoint. Enrolling again before
+// @Description that replaces the secret and the recovery codes.
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 201 {object} TotpEnrollmentResponse "TOTP secret and recovery codes"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 409 {object} ErrorResponse "Two-factor authentication already enabled"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/2fa [post]
+func (u *UserHandler) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	user, err := u.queries.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeUserNotFoundError(w, userID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	secret, encryptedSecret, err := u.totp.NewSecret()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	err = withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		params := db.UpsertUserTotpParams{
+			UserID: userID,
+			Secret: encryptedSecret,
+		}
+		if _, err := q.UpsertUserTotp(r.Context(), params); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errTwoFactorEnabled
+			}
+			return err
+		}
+
+		if err := q.DeleteUserRecoveryCodes(r.Context(), userID); err != nil {
+			return err
+		}
+		for _, codeHash := range recoveryCodeHashes {
+			params := db.CreateRecoveryCodeParams{
+				UserID:   userID,
+				CodeHash: codeHash,
+			}
+			if err := q.CreateRecoveryCode(r.Context(), params); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errTwoFactorEnabled) {
+			writeTwoFactorEnabledError(w)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	response := TotpEnrollmentResponse{
+		Secret:        secret,
+		OtpauthURI:    u.totp.URI(user.Username, secret),
+		RecoveryCodes: recoveryCodes,
+	}
+	writeJson(w, response, http.StatusCreated)
+}
+
+// @Summary Verify two-factor authentication
+// @Description Confirm the enrollment with a code of the authenticator app.
+// @Description From then on the login requires a second step.
+// @Tags User
+// @Accept json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param code body TotpCodeRequest true "TOTP code"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Not enrolled"
+// @Failure 409 {object} ErrorResponse "Two-factor authentication already enabled"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/2fa/verify [post]
+func (u *UserHandler) verifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	request := &TotpCodeRequest{}
+
+	if !decodeAndValidate(w, r, request) {
+		return
+	}
+
+	userTotp, err := u.queries.GetUserTotp(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeTwoFactorDisabledError(w)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+	if userTotp.EnabledAt.Valid {
+		writeTwoFactorEnabledError(w)
+		return
+	}
+
+	step, ok, err := u.totp.Validate(userTotp.Secret, request.Code, userTotp.LastUsedStep)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+	if !ok {
+		writeInvalidTotpCodeError(w)
+		return
+	}
+
+	params := db.EnableUserTotpParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	}
+	err = withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		if err := q.EnableUserTotp(r.Context(), params); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityUser, userID, nil, auditCredential{Credential: "totp"})
+	})
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Disable two-factor authentication
+// @Description Remove the TOTP secret and the recovery codes of the user.
+// @Description Users have to confirm this with a TOTP or recovery code.
+// @Description Administrators can disable it for other users without a code.
+// @Tags User
+// @Accept json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param code body TotpCodeRequest false "TOTP or recovery code"
+// @Success 204 "No content"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "Not enrolled"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/2fa [delete]
+func (u *UserHandler) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	userTotp, err := u.queries.GetUserTotp(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeTwoFactorDisabledError(w)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	if isSelf(r) && userTotp.EnabledAt.Valid {
+		request := &TotpCodeRequest{}
+
+		if !decodeAndValidate(w, r, request) {
+			return
+		}
+
+		ok, err := verifySecondFactor(r.Context(), u.queries, u.totp, userTotp, request.Code)
+		if err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+		if !ok {
+			writeInvalidTotpCodeError(w)
+			return
+		}
+	}
+
+	err = withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		if err := q.DeleteUserTotp(r.Context(), userID); err != nil {
+			return err
+		}
+		if err := q.DeleteUserRecoveryCodes(r.Context(), userID); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionDelete, auditEntityUser, userID, auditCredential{Credential: "totp"}, nil)
+	})
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifySecondFactor accepts either a TOTP code or one of the recovery codes
+// of the user. Both can only be used once.
+func verifySecondFactor(ctx context.Context, q *db.Queries, totp *auth.TotpManager, userTotp db.UserTotp, code string) (bool, error) {
+	step, ok, err := totp.Validate(userTotp.Secret, code, userTotp.LastUsedStep)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		params := db.UseTotpStepParams{
+			UserID:       userTotp.UserID,
+			LastUsedStep: step,
+		}
+		rows, err := q.UseTotpStep(ctx, params)
+		return rows == 1, err
+	}
+
+	params := db.UseRecoveryCodeParams{
+		UserID:   userTotp.UserID,
+		CodeHash: auth.HashRecoveryCode(code),
+	}
+	rows, err := q.UseRecoveryCode(ctx, params)
+	return rows == 1, err
+}
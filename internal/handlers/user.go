@@ -3,41 +3,88 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"slices"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mderler/simple-go-backend/internal/auth"
 	"github.com/mderler/simple-go-backend/internal/db"
 )
 
+var (
+	errCurrentPasswordMissing = errors.New("the current password is missing")
+	errCurrentPasswordWrong   = errors.New("the current password is wrong")
+)
+
 type UserHandler struct {
 	*chi.Mux
-	queries *db.Queries
+	conn      *pgxpool.Pool
+	queries   *db.Queries
+	passwords *auth.PasswordHasher
+	totp      *auth.TotpManager
+	verifier  *EmailVerifier
 }
 
-func NewUserHandler(queries *db.Queries) *UserHandler {
-	userHandler := &UserHandler{chi.NewRouter(), queries}
+func NewUserHandler(conn *pgxpool.Pool, queries *db.Queries, passwords *auth.PasswordHasher, totp *auth.TotpManager, verifier *EmailVerifier, authn *Authenticator, limiter *RateLimiter) *UserHandler {
+	userHandler := &UserHandler{chi.NewRouter(), conn, queries, passwords, totp, verifier}
 
-	userHandler.Post("/", userHandler.createUser)
-	userHandler.Get("/", userHandler.getUsers)
+	userHandler.With(limiter.Limit("user")).Post("/", userHandler.createUser)
 
 	userHandler.Group(func(r chi.Router) {
-		r.Use(userCtx)
-		r.Put("/{id}", userHandler.updateUser)
-		r.Delete("/{id}", userHandler.deleteUser)
-		r.Get("/{id}/todos", userHandler.getUserTodos)
+		r.Use(authn.Middleware)
+		r.Use(csrfProtect)
+		r.Use(limiter.Limit("user"))
+		r.With(authorize(isAdmin)).Get("/", userHandler.getUsers)
+		r.With(authorize(isAdmin)).Get("/lockouts", userHandler.getLockouts)
+
+		r.Group(func(r chi.Router) {
+			r.Use(userCtx)
+
+			r.Group(func(r chi.Router) {
+				r.Use(authorize(isSelf, isAdmin))
+				r.Put("/{id}", userHandler.updateUser)
+				r.Patch("/{id}", userHandler.patchUser)
+				r.Delete("/{id}", userHandler.deleteUser)
+				r.Get("/{id}/todos", userHandler.getUserTodos)
+				r.Get("/{id}/api-keys", userHandler.getApiKeys)
+				r.With(apiKeyCtx).Delete("/{id}/api-keys/{keyId}", userHandler.revokeApiKey)
+				r.Delete("/{id}/2fa", userHandler.disableTwoFactor)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(authorize(isSelf))
+				r.Post("/{id}/api-keys", userHandler.createApiKey)
+				r.Post("/{id}/2fa", userHandler.enrollTwoFactor)
+				r.Post("/{id}/2fa/verify", userHandler.verifyTwoFactor)
+				r.Post("/{id}/verify-email", userHandler.resendVerification)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(authorize(isAdmin))
+				r.Put("/{id}/role", userHandler.updateUserRole)
+				r.Delete("/{id}/lockout", userHandler.unlockUser)
+			})
+		})
 	})
 	return userHandler
 }
 
 // @Summary Create a new user
-// @Description Create a new user with the provided user data.
+// @Description Create a new user with the provided user data. A link to
+// @Description verify the email address is mailed to the user.
 // @Tags User
 // @Accept json
 // @Produce json
 // @Param user body UserRequest true "User data"
-// @Success 201 {object} db.User "Created user"
+// @Success 201 {object} UserResponse "Created user"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 409 {object} ErrorResponse "Username or email already taken"
 // @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /user [post]
 func (u *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
@@ -47,21 +94,49 @@ func (u *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	params := db.CreateUserParams(*user)
-	dbUser, err := u.queries.CreateUser(r.Context(), params)
+	passwordHash, err := u.passwords.Hash(user.Password)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
+	params := db.CreateUserParams{
+		Username:     user.Username,
+		Email:        user.Email,
+		PasswordHash: passwordHash,
+	}
+	var dbUser db.User
+	err = withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		dbUser, err = q.CreateUser(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionCreate, auditEntityUser, dbUser.ID, nil, newUserResponse(dbUser))
+	})
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			writeInvalidUserRequestError(w, pgErr)
+			return
+		}
 		writeInternalServerError(w, err)
 		return
 	}
 
-	writeJson(w, dbUser, http.StatusCreated)
+	go u.verifier.Send(dbUser)
+
+	writeJson(w, newUserResponse(dbUser), http.StatusCreated)
 }
 
 // @Summary Get all users
-// @Description Get the list of all users.
+// @Description Get the list of all users. Only administrators may do this.
 // @Tags User
 // @Produce json
-// @Success 200 {array} db.User "List of users"
+// @Security BearerAuth
+// @Success 200 {array} UserResponse "List of users"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /user [get]
 func (u *UserHandler) getUsers(w http.ResponseWriter, r *http.Request) {
@@ -71,20 +146,35 @@ func (u *UserHandler) getUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJson(w, users, http.StatusOK)
+	response := make([]UserResponse, len(users))
+	for i, user := range users {
+		response[i] = newUserResponse(user)
+	}
+
+	writeJson(w, response, http.StatusOK)
 }
 
 // @Summary Update an existing user
-// @Description Update an existing user with the provided user data.
+// @Description Update an existing user with the provided user data. Changing
+// @Description the email address mails a link to verify the new address.
+// @Description Changes that only differ in case keep the address verified.
+// @Description Users that change their own password have to send their
+// @Description current one. A new password revokes all refresh tokens and
+// @Description sessions of the user.
 // @Tags User
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path int true "User ID"
 // @Param user body UserRequest true "User data"
-// @Success 200 {object} db.User "Updated user"
+// @Success 200 {object} UserResponse "Updated user"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 409 {object} ErrorResponse "Username or email already taken"
 // @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /user/{id} [put]
 func (u *UserHandler) updateUser(w http.ResponseWriter, r *http.Request) {
@@ -96,60 +186,288 @@ func (u *UserHandler) updateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	passwordHash, err := u.passwords.Hash(user.Password)
+	if err != nil {
+		writeInternalServerError(w, err)
+		return
+	}
+
 	params := db.UpdateUserParams{
-		ID:       int32(userID),
-		Username: user.Username,
-		Email:    user.Email,
-		Password: user.Password,
+		ID:           userID,
+		Username:     user.Username,
+		Email:        user.Email,
+		PasswordHash: passwordHash,
+	}
+	u.saveUser(w, r, userID, user.CurrentPassword, func(before db.User) (db.UpdateUserParams, error) {
+		return params, nil
+	})
+}
+
+// @Summary Partially update a user
+// @Description Update the fields of a user that are part of the JSON Merge
+// @Description Patch (RFC 7396). Changing the email address requires
+// @Description verifying it again. Users that change their own password have
+// @Description to send their current one. A new password revokes all refresh
+// @Description tokens and sessions of the user.
+// @Tags User
+// @Accept json,application/merge-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param user body UserPatchRequest true "Changed fields"
+// @Success 200 {object} UserResponse "Updated user"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 409 {object} ErrorResponse "Username or email already taken"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id} [patch]
+func (u *UserHandler) patchUser(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	user := &UserPatchRequest{}
+
+	patch, ok := decodeMergePatch(w, r, user)
+	if !ok {
+		return
 	}
 
-	dbUser, err := u.queries.UpdateUser(r.Context(), params)
+	// Hashing is slow, so it happens before the user is locked.
+	var passwordHash string
+	if user.Password != "" {
+		var err error
+		passwordHash, err = u.passwords.Hash(user.Password)
+		if err != nil {
+			writeInternalServerError(w, err)
+			return
+		}
+	}
+
+	u.saveUser(w, r, userID, user.CurrentPassword, func(before db.User) (db.UpdateUserParams, error) {
+		user := &UserPatchRequest{
+			Username: before.Username,
+			Email:    before.Email,
+		}
+		if err := patch.applyTo(user); err != nil {
+			return db.UpdateUserParams{}, err
+		}
+		// The patch was validated on its own, so the password still has to be
+		// compared with the username and email address that aren't part of it.
+		if user.Password != "" && auth.ContainsPersonalInfo(user.Password, user.Username, user.Email) {
+			return db.UpdateUserParams{}, errPersonalPassword
+		}
+
+		params := db.UpdateUserParams{
+			ID:           userID,
+			Username:     user.Username,
+			Email:        user.Email,
+			PasswordHash: before.PasswordHash,
+		}
+		if passwordHash != "" {
+			params.PasswordHash = passwordHash
+		}
+		return params, nil
+	})
+}
+
+// saveUser locks the user, updates them with what update returns for the
+// current state, records the change in the audit log and writes the updated
+// user. Users that change their own password have to confirm it with
+// currentPassword, a new password revokes all refresh tokens and sessions
+// like a password reset. Changing the email address to a new one, regardless
+// of case, mails a new verification link.
+func (u *UserHandler) saveUser(w http.ResponseWriter, r *http.Request, userID int32, currentPassword string, update func(before db.User) (db.UpdateUserParams, error)) {
+	var before, dbUser db.User
+	err := withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		var err error
+		before, err = q.GetUserForUpdate(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+
+		params, err := update(before)
+		if err != nil {
+			return err
+		}
+
+		passwordChanged := params.PasswordHash != before.PasswordHash
+		if passwordChanged && isSelf(r) {
+			if currentPassword == "" {
+				return errCurrentPasswordMissing
+			}
+			match, _, err := u.passwords.Verify(currentPassword, before.PasswordHash)
+			if err != nil {
+				return err
+			}
+			if !match {
+				return errCurrentPasswordWrong
+			}
+		}
+
+		dbUser, err = q.UpdateUser(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityUser, userID, newUserResponse(before), newUserResponse(dbUser)); err != nil {
+			return err
+		}
+		if !passwordChanged {
+			return nil
+		}
+
+		if err := recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityUser, userID, nil, auditCredential{Credential: "password"}); err != nil {
+			return err
+		}
+		if err := q.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+			return err
+		}
+		return q.DeleteUserSessions(r.Context(), userID)
+	})
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			writeUserNotFoundError(w, userID)
 			return
 		}
+		if errors.Is(err, errPersonalPassword) {
+			writeJson(w, newValidationErrorResponse("password_personal", "Password"), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, errCurrentPasswordMissing) {
+			writeJson(w, newValidationErrorResponse("required", "CurrentPassword"), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, errCurrentPasswordWrong) {
+			writeJson(w, newValidationErrorResponse("password_current", "CurrentPassword"), http.StatusUnprocessableEntity)
+			return
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			writeInvalidUserRequestError(w, pgErr)
+			return
+		}
 		writeInternalServerError(w, err)
 		return
 	}
 
-	writeJson(w, dbUser, http.StatusOK)
+	if !strings.EqualFold(before.Email, dbUser.Email) && !dbUser.EmailVerifiedAt.Valid {
+		go u.verifier.Send(dbUser)
+	}
+
+	writeJson(w, newUserResponse(dbUser), http.StatusOK)
 }
 
 // @Summary Delete an existing user
-// @Description Delete an existing user with the provided user ID.
+// @Description Delete an existing user with the provided user ID. Their todos
+// @Description are deleted together with all of their subtasks, including the
+// @Description ones of other users. Everything that is deleted together with
+// @Description the user is recorded in the audit log, including their
+// @Description comments, attachments and assignments on other todos.
 // @Tags User
+// @Security BearerAuth
 // @Param id path int true "User ID"
 // @Success 204 "No content"
 // @Failure 400 {object} ErrorResponse "Bad Request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /user/{id} [delete]
 func (u *UserHandler) deleteUser(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(int32)
 
-	affectedRows, err := u.queries.DeleteUser(r.Context(), int32(userID))
+	err := withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		// Locking the user keeps others from creating anything that refers to
+		// them, like todos or assignments, until they are deleted.
+		before, err := q.GetUserForUpdate(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+
+		// The todos of the user are deleted together with their subtasks,
+		// including the ones that other users created below them. Subtasks
+		// can be added or moved while this waits for the locks of their
+		// trees, so they are listed again until nothing changed.
+		var ids []int32
+		for {
+			todos, err := q.ListTodoTreesOfCreator(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+			if slices.Equal(todoIDs(todos), ids) {
+				break
+			}
+			ids = todoIDs(todos)
+			if err := lockTodoTrees(r.Context(), q, ids...); err != nil {
+				return err
+			}
+		}
+		if err := recordTodoDeletions(r.Context(), q, ids, pgtype.Int4{Int32: userID, Valid: true}); err != nil {
+			return err
+		}
+
+		apiKeys, err := q.ListApiKeysOfUser(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		for _, apiKey := range apiKeys {
+			if err := recordAudit(r.Context(), q, db.AuditActionDelete, auditEntityApiKey, apiKey.ID, newApiKeyResponse(apiKey), nil); err != nil {
+				return err
+			}
+		}
+
+		// Labels outlive their creator, who is only removed from them.
+		labels, err := q.ListLabelsOfCreator(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		for _, label := range labels {
+			after := label
+			after.CreatorID = nil
+			if err := recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityLabel, label.ID, label, after); err != nil {
+				return err
+			}
+		}
+
+		if _, err := q.DeleteUser(r.Context(), userID); err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionDelete, auditEntityUser, userID, newUserResponse(before), nil)
+	})
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeUserNotFoundError(w, userID)
+			return
+		}
 		writeInternalServerError(w, err)
 		return
 	}
-	if affectedRows == 0 {
-		writeUserNotFoundError(w, userID)
-		return
-	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // @Summary Get all todos of a user
 // @Description Get the list of all todos of a user with the provided user ID.
+// @Description Todos are overdue if their due date has passed and they aren't completed.
 // @Tags User
 // @Produce json
+// @Security BearerAuth
 // @Param id path int true "User ID"
 // @Param type query string false "Type of todos to get" Enums(assigned, created)
+// @Param overdue query bool false "Only get todos that are or aren't overdue"
+// @Param priority query string false "Only get todos with this priority" Enums(none, low, medium, high, urgent)
+// @Param label query []int false "Only get todos with these label IDs" collectionFormat(csv)
+// @Param label_match query string false "Whether todos need any or all of the labels (default any)" Enums(any, all)
+// @Param sort query string false "Sort by creation or by priority, newest first (default created)" Enums(created, priority)
 // @Success 200 {array} db.Todo "List of todos"
 // @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
 // @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 429 {object} ErrorResponse "Too many requests"
 // @Failure 500 {object} InternalErrorResponse "Internal server error"
 // @Router /user/{id}/todos [get]
 func (u *UserHandler) getUserTodos(w http.ResponseWriter, r *http.Request) {
@@ -158,14 +476,44 @@ func (u *UserHandler) getUserTodos(w http.ResponseWriter, r *http.Request) {
 	var todos []db.Todo
 	var err error
 
+	overdue, ok := parseBoolQuery(w, r.URL.Query(), "overdue")
+	if !ok {
+		return
+	}
+	list, ok := parseTodoListQuery(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
 	q := r.URL.Query().Get("type")
 	switch q {
 	case "assigned":
-		todos, err = u.queries.GetAssignedTodosOfUser(r.Context(), userID)
+		todos, err = u.queries.GetAssignedTodosOfUser(r.Context(), db.GetAssignedTodosOfUserParams{
+			UserID:         userID,
+			Overdue:        overdue,
+			Priority:       list.priority,
+			LabelIds:       list.labelIDs,
+			MatchAllLabels: list.matchAllLabels,
+			Sort:           list.sort,
+		})
 	case "created":
-		todos, err = u.queries.GetCreatedTodosOfUser(r.Context(), userID)
+		todos, err = u.queries.GetCreatedTodosOfUser(r.Context(), db.GetCreatedTodosOfUserParams{
+			CreatorID:      userID,
+			Overdue:        overdue,
+			Priority:       list.priority,
+			LabelIds:       list.labelIDs,
+			MatchAllLabels: list.matchAllLabels,
+			Sort:           list.sort,
+		})
 	case "":
-		todos, err = u.queries.GetAllTodosOfUser(r.Context(), userID)
+		todos, err = u.queries.GetAllTodosOfUser(r.Context(), db.GetAllTodosOfUserParams{
+			UserID:         userID,
+			Overdue:        overdue,
+			Priority:       list.priority,
+			LabelIds:       list.labelIDs,
+			MatchAllLabels: list.matchAllLabels,
+			Sort:           list.sort,
+		})
 	default:
 		writeInvalidQueryError(w, q, []string{"assigned", "created", ""})
 		return
@@ -178,3 +526,100 @@ func (u *UserHandler) getUserTodos(w http.ResponseWriter, r *http.Request) {
 
 	writeJson(w, todos, http.StatusOK)
 }
+
+// @Summary Change the role of a user
+// @Description Change the role of an existing user. Only administrators may
+// @Description do this and they can't change their own role.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param role body UserRoleRequest true "Role data"
+// @Success 200 {object} UserResponse "Updated user"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 422 {object} ValidationErrorResponse "Validation error"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/role [put]
+func (u *UserHandler) updateUserRole(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	if isSelf(r) {
+		writeForbiddenError(w)
+		return
+	}
+
+	role := &UserRoleRequest{}
+
+	if !decodeAndValidate(w, r, role) {
+		return
+	}
+
+	params := db.UpdateUserRoleParams{
+		ID:   userID,
+		Role: role.Role,
+	}
+	var dbUser db.User
+	err := withTx(r.Context(), u.conn, u.queries, func(q *db.Queries) error {
+		before, err := q.GetUser(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+
+		dbUser, err = q.UpdateUserRole(r.Context(), params)
+		if err != nil {
+			return err
+		}
+		return recordAudit(r.Context(), q, db.AuditActionUpdate, auditEntityUser, userID, newUserResponse(before), newUserResponse(dbUser))
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeUserNotFoundError(w, userID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+
+	writeJson(w, newUserResponse(dbUser), http.StatusOK)
+}
+
+// @Summary Resend the verification mail
+// @Description Mail a new link to verify the email address of the user.
+// @Tags User
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 202 "Accepted"
+// @Failure 400 {object} ErrorResponse "Bad request"
+// @Failure 401 {object} ErrorResponse "Unauthorized"
+// @Failure 403 {object} ErrorResponse "Forbidden"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 409 {object} ErrorResponse "Email already verified"
+// @Failure 429 {object} ErrorResponse "Too many requests"
+// @Failure 500 {object} InternalErrorResponse "Internal server error"
+// @Router /user/{id}/verify-email [post]
+func (u *UserHandler) resendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(int32)
+
+	user, err := u.queries.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeUserNotFoundError(w, userID)
+			return
+		}
+		writeInternalServerError(w, err)
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		writeEmailVerifiedError(w)
+		return
+	}
+
+	go u.verifier.Send(user)
+
+	w.WriteHeader(http.StatusAccepted)
+}
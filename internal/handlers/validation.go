@@ -2,18 +2,72 @@ package handlers
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/mderler/simple-go-backend/internal/auth"
 )
 
 var validate *validator.Validate
 
+// passwordPolicy is used by the password tag. It is replaced with
+// SetPasswordPolicy before the server starts.
+var passwordPolicy = auth.DefaultPasswordPolicy
+
 func init() {
 	validate = validator.New(validator.WithRequiredStructEnabled())
+
+	validate.RegisterValidation("password_length", func(fl validator.FieldLevel) bool {
+		return passwordPolicy.LongEnough(fl.Field().String())
+	})
+	validate.RegisterValidation("password_classes", func(fl validator.FieldLevel) bool {
+		return passwordPolicy.HasClasses(fl.Field().String())
+	})
+	validate.RegisterValidation("password_entropy", func(fl validator.FieldLevel) bool {
+		return passwordPolicy.StrongEnough(fl.Field().String())
+	})
+	validate.RegisterValidation("password_common", func(fl validator.FieldLevel) bool {
+		return !passwordPolicy.IsCommon(fl.Field().String())
+	})
+	validate.RegisterValidation("password_personal", validatePasswordPersonal)
+	// password checks every rule of the policy. The failed rule is reported
+	// as the tag of the invalid param.
+	validate.RegisterAlias("password", "password_length,password_classes,password_entropy,password_common,password_personal")
+}
+
+// SetPasswordPolicy replaces the policy of the password tag. It isn't safe to
+// call while requests are validated.
+func SetPasswordPolicy(policy auth.PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// validatePasswordPersonal rejects passwords that contain the Username or the
+// Email field of the same request.
+func validatePasswordPersonal(fl validator.FieldLevel) bool {
+	var values []string
+	parent := fl.Parent()
+	if parent.Kind() == reflect.Struct {
+		for _, name := range []string{"Username", "Email"} {
+			if field := parent.FieldByName(name); field.IsValid() && field.Kind() == reflect.String {
+				values = append(values, field.String())
+			}
+		}
+	}
+
+	return !auth.ContainsPersonalInfo(fl.Field().String(), values...)
 }
 
 func Validate(s interface{}) *ValidationErrorResponse {
-	err := validate.Struct(s)
+	return validationErrorResponse(validate.Struct(s))
+}
+
+// validatePartial only validates the given fields of the struct, which are
+// named like in the struct and not like in the JSON.
+func validatePartial(s interface{}, fields ...string) *ValidationErrorResponse {
+	return validationErrorResponse(validate.StructPartial(s, fields...))
+}
+
+func validationErrorResponse(err error) *ValidationErrorResponse {
 	if err == nil {
 		return nil
 	}
@@ -23,7 +77,7 @@ func Validate(s interface{}) *ValidationErrorResponse {
 	invalidParams := make(map[string]InvalidParam)
 	for _, e := range err.(validator.ValidationErrors) {
 		fieldName := e.Field()
-		tag := e.Tag()
+		tag := e.ActualTag()
 		message := fmt.Sprintf("The %s %s", fieldName, getErrorMessage(tag))
 		invalidParams[fieldName] = InvalidParam{Message: message, Tag: tag}
 	}
@@ -32,6 +86,23 @@ func Validate(s interface{}) *ValidationErrorResponse {
 	return errorResponse
 }
 
+// newValidationErrorResponse reports fields that failed the same check
+// outside of the validator, e.g. because it can only run after the request
+// was validated.
+func newValidationErrorResponse(tag string, fieldNames ...string) *ValidationErrorResponse {
+	invalidParams := make(map[string]InvalidParam)
+	for _, fieldName := range fieldNames {
+		message := fmt.Sprintf("The %s %s", fieldName, getErrorMessage(tag))
+		invalidParams[fieldName] = InvalidParam{Message: message, Tag: tag}
+	}
+
+	return &ValidationErrorResponse{
+		Type:          "validation-error",
+		Detail:        "Your request parameters didn't validate.",
+		InvalidParams: invalidParams,
+	}
+}
+
 func getErrorMessage(tag string) string {
 	switch tag {
 	case "required":
@@ -40,6 +111,20 @@ func getErrorMessage(tag string) string {
 		return "value is too short"
 	case "email":
 		return "field must be a valid email"
+	case "excludes":
+		return "field contains a character that isn't allowed"
+	case "password_length":
+		return fmt.Sprintf("field must be at least %d characters long", passwordPolicy.MinLength)
+	case "password_classes":
+		return fmt.Sprintf("field must contain at least %d of lowercase letters, uppercase letters, digits and symbols", passwordPolicy.MinClasses)
+	case "password_entropy":
+		return "field is too easy to guess, use a longer password with more different characters"
+	case "password_common":
+		return "field is too common or appeared in a data breach"
+	case "password_personal":
+		return "field must not contain the username or the email address"
+	case "password_current":
+		return "field doesn't match the current password"
 	default:
 		return "invalid value"
 	}
@@ -0,0 +1,16 @@
+package mailer
+
+import (
+	"context"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain text emails to users.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
@@ -0,0 +1,28 @@
+package notifier
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// LogNotifier writes reminders to a logger instead of delivering them, e.g.
+// during local development.
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, reminder Reminder) error {
+	n.logger.Printf("Reminder for user %d (%s): todo %d %q is due at %s\n",
+		reminder.UserID,
+		reminder.Username,
+		reminder.TodoID,
+		reminder.Title,
+		reminder.DueAt.Format(time.RFC3339),
+	)
+	return nil
+}
@@ -0,0 +1,28 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mderler/simple-go-backend/internal/mailer"
+)
+
+// MailNotifier sends reminders by email.
+type MailNotifier struct {
+	mailer mailer.Mailer
+}
+
+func NewMailNotifier(m mailer.Mailer) *MailNotifier {
+	return &MailNotifier{m}
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, reminder Reminder) error {
+	return n.mailer.Send(ctx, mailer.Message{
+		To:      reminder.Email,
+		Subject: fmt.Sprintf("Reminder: %s", reminder.Title),
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"the todo \"%s\" is due at %s.\n",
+			reminder.Username, reminder.Title, reminder.DueAt.Format(time.RFC1123Z)),
+	})
+}
@@ -0,0 +1,21 @@
+package notifier
+
+import (
+	"context"
+	"time"
+)
+
+// Reminder tells a user that a todo is due soon.
+type Reminder struct {
+	TodoID   int32
+	Title    string
+	DueAt    time.Time
+	UserID   int32
+	Username string
+	Email    string
+}
+
+// Notifier delivers reminders to users.
+type Notifier interface {
+	Notify(ctx context.Context, reminder Reminder) error
+}
@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"context"
+	"log"
+
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// reminderBatchSize is the number of reminders that are claimed at once.
+const reminderBatchSize = 100
+
+// ReminderScheduler sends the reminders of todos to their creator and
+// assignees once they are due. Reminders are claimed before they are sent, so
+// that several instances of the API don't send them twice. Failed deliveries
+// are logged and not retried.
+type ReminderScheduler struct {
+	queries  *db.Queries
+	notifier Notifier
+}
+
+func NewReminderScheduler(queries *db.Queries, notifier Notifier) *ReminderScheduler {
+	return &ReminderScheduler{queries, notifier}
+}
+
+// SendDue sends every reminder that is due, e.g. run with jobs.Every.
+func (s *ReminderScheduler) SendDue(ctx context.Context) error {
+	for {
+		reminders, err := s.queries.ClaimDueTodoReminders(ctx, reminderBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, reminder := range reminders {
+			s.send(ctx, reminder)
+		}
+
+		if len(reminders) < reminderBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *ReminderScheduler) send(ctx context.Context, reminder db.ClaimDueTodoRemindersRow) {
+	users, err := s.queries.ListTodoRecipients(ctx, reminder.TodoID)
+	if err != nil {
+		log.Printf("Error sending reminder %d: %v\n", reminder.ID, err)
+		return
+	}
+
+	for _, user := range users {
+		err := s.notifier.Notify(ctx, Reminder{
+			TodoID:   reminder.TodoID,
+			Title:    reminder.Title,
+			DueAt:    *reminder.DueAt,
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+		})
+		if err != nil {
+			log.Printf("Error sending reminder %d to user %d: %v\n", reminder.ID, user.ID, err)
+		}
+	}
+}
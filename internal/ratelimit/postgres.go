@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// PostgresStore keeps the buckets in the rate_limit_bucket table, so that
+// several instances of the API share the same limits.
+type PostgresStore struct {
+	queries *db.Queries
+}
+
+func NewPostgresStore(queries *db.Queries) *PostgresStore {
+	return &PostgresStore{queries}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	params := db.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(limit.Burst),
+		Rate:  limit.Rate,
+	}
+	tokens, err := s.queries.TakeRateLimitToken(ctx, params)
+	if err == nil {
+		return newResult(true, tokens, limit), nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Result{}, err
+	}
+
+	// The upsert doesn't return a row if the bucket is empty.
+	tokens, err = s.queries.GetRateLimitTokens(ctx, db.GetRateLimitTokensParams{
+		Burst: float64(limit.Burst),
+		Rate:  limit.Rate,
+		Key:   key,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(false, tokens, limit), nil
+}
+
+func (s *PostgresStore) Cleanup(ctx context.Context, idle time.Duration) (int64, error) {
+	return s.queries.DeleteIdleRateLimitBuckets(ctx, int32(idle.Seconds()))
+}
@@ -0,0 +1,83 @@
+// Package ratelimit implements token bucket rate limiting with pluggable
+// storage backends.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit describes a token bucket that holds at most Burst tokens and gets
+// refilled with Rate tokens per second. Every request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses limits of the form <requests>/<period>, e.g. "30/1m".
+// The bucket allows bursts of up to <requests> requests.
+func ParseLimit(s string) (Limit, error) {
+	requestsStr, periodStr, found := strings.Cut(s, "/")
+	if !found {
+		return Limit{}, fmt.Errorf("invalid limit %q: expected <requests>/<period>", s)
+	}
+
+	requests, err := strconv.Atoi(requestsStr)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: requests must be a positive integer", s)
+	}
+
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: period must be a positive duration", s)
+	}
+
+	return Limit{Rate: float64(requests) / period.Seconds(), Burst: requests}, nil
+}
+
+// durationFor returns how long it takes to refill the amount of tokens.
+func (l Limit) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket.
+	Limit int
+	// Remaining is the number of requests that are allowed right now.
+	Remaining int
+	// ResetAfter is the time until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next request is allowed. It is zero
+	// if the request was allowed.
+	RetryAfter time.Duration
+}
+
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	result := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Max(0, math.Floor(tokens))),
+		ResetAfter: limit.durationFor(float64(limit.Burst) - tokens),
+	}
+	if !allowed {
+		result.RetryAfter = limit.durationFor(1 - tokens)
+	}
+	return result
+}
+
+// Store keeps track of the token buckets.
+type Store interface {
+	// Take tries to take a token from the bucket with the given key.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Cleanup removes buckets that haven't been used for the idle duration.
+	Cleanup(ctx context.Context, idle time.Duration) (int64, error)
+}
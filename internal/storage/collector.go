@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/mderler/simple-go-backend/internal/db"
+)
+
+// orphanBatchSize is the number of orphaned blobs that are deleted at once.
+const orphanBatchSize = 100
+
+// orphanMinAge keeps young orphans around. Uploads queue their blob before
+// storing it and only take it off the queue once the attachment exists, so
+// a young orphan may belong to an upload that is still in progress.
+const orphanMinAge = time.Hour
+
+// OrphanCollector deletes the blobs of deleted attachments and failed
+// uploads. The database queues the storage keys of every deleted attachment,
+// including the ones deleted together with their todo or uploader.
+type OrphanCollector struct {
+	queries *db.Queries
+	store   BlobStore
+}
+
+func NewOrphanCollector(queries *db.Queries, store BlobStore) *OrphanCollector {
+	return &OrphanCollector{queries, store}
+}
+
+// DeleteOrphans deletes every queued blob, e.g. run with jobs.Every. Blobs
+// that can't be deleted stay queued for the next run.
+func (c *OrphanCollector) DeleteOrphans(ctx context.Context) error {
+	for {
+		keys, err := c.queries.ListOrphanedBlobs(ctx, db.ListOrphanedBlobsParams{
+			MinAgeSeconds: int32(orphanMinAge.Seconds()),
+			BatchSize:     orphanBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := c.store.Delete(ctx, key); err != nil {
+				return err
+			}
+			if err := c.queries.DeleteOrphanedBlob(ctx, key); err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < orphanBatchSize {
+			return nil
+		}
+	}
+}
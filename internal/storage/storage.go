@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Get if no blob is stored under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores the contents of files under keys. Keys are chosen by the
+// caller and may only contain letters, digits, dashes and underscores.
+type BlobStore interface {
+	// Put stores size bytes of r under the key, replacing an existing blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under the key. The caller has to close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under the key. Deleting a missing blob
+	// isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+func validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "user" RENAME COLUMN password TO password_hash;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "user" RENAME COLUMN password_hash TO password;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE user_role AS ENUM ('admin', 'member');
+
+ALTER TABLE "user" ADD COLUMN role user_role DEFAULT 'member' NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "user" DROP COLUMN role;
+
+DROP TYPE user_role;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "user" ADD COLUMN email_verified_at TIMESTAMP;
+
+-- Accounts that existed before email verification are trusted as before.
+UPDATE "user" SET email_verified_at = CURRENT_TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "user" DROP COLUMN email_verified_at;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE login_throttle (
+    key VARCHAR(255) PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMP,
+    last_failure_at TIMESTAMP NOT NULL
+);
+
+CREATE TYPE lockout_action AS ENUM ('locked', 'unlocked');
+
+CREATE TABLE lockout_event (
+    id SERIAL PRIMARY KEY,
+    action lockout_action NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    user_id INTEGER,
+    actor_id INTEGER,
+    ip VARCHAR(64),
+    failures INTEGER NOT NULL,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES "user"(id) ON DELETE SET NULL,
+    FOREIGN KEY (actor_id) REFERENCES "user"(id) ON DELETE SET NULL
+);
+
+CREATE INDEX lockout_event_user_id_idx ON lockout_event (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE lockout_event;
+DROP TYPE lockout_action;
+DROP TABLE login_throttle;
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Emails are used to sign in and receive mails, so duplicates aren't
+-- resolved here. The migration aborts and lists them, and an operator has to
+-- resolve them by hand before it can run.
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(email || ' (users ' || ids || ')', ', ')
+    INTO conflicts
+    FROM (
+        SELECT email, string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+        FROM (SELECT id, LOWER(email) AS email FROM "user") AS u
+        GROUP BY email
+        HAVING COUNT(*) > 1
+    ) AS duplicates;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'emails used by more than one user: %', conflicts;
+    END IF;
+END;
+$$;
+
+-- Usernames used to be unique only by case, so users that share a username
+-- with an older user get their ID appended to theirs. The username column
+-- holds at most 20 characters, so the name is shortened to make room. If the
+-- new name is taken as well, a counter is appended until it is free.
+DO $$
+DECLARE
+    duplicate RECORD;
+    suffix TEXT;
+    candidate TEXT;
+    attempt INT;
+BEGIN
+    FOR duplicate IN
+        SELECT id, username FROM "user"
+        WHERE id NOT IN (
+            SELECT MIN(id) FROM "user" GROUP BY LOWER(username)
+        )
+        ORDER BY id
+    LOOP
+        attempt := 0;
+        LOOP
+            suffix := '_' || duplicate.id || CASE WHEN attempt > 0 THEN '_' || attempt ELSE '' END;
+            candidate := LEFT(duplicate.username, 20 - LENGTH(suffix)) || suffix;
+            EXIT WHEN NOT EXISTS (
+                SELECT 1 FROM "user" WHERE LOWER(username) = LOWER(candidate)
+            );
+            attempt := attempt + 1;
+        END LOOP;
+
+        UPDATE "user" SET username = candidate WHERE id = duplicate.id;
+    END LOOP;
+END;
+$$;
+
+CREATE UNIQUE INDEX user_username_key ON "user" (LOWER(username));
+CREATE UNIQUE INDEX user_email_key ON "user" (LOWER(email));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX user_email_key;
+DROP INDEX user_username_key;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE audit_action AS ENUM ('create', 'update', 'delete', 'assign');
+
+-- actor_id has no foreign key on purpose, the audit log has to outlive the
+-- users it mentions.
+CREATE TABLE audit_event (
+    id SERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    action audit_action NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    before_state JSONB,
+    after_state JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX audit_event_actor_id_idx ON audit_event (actor_id);
+CREATE INDEX audit_event_entity_idx ON audit_event (entity_type, entity_id);
+CREATE INDEX audit_event_created_at_idx ON audit_event (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE audit_event;
+DROP TYPE audit_action;
+-- +goose StatementEnd
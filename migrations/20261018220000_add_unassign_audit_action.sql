@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TYPE audit_action ADD VALUE 'unassign';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM audit_event WHERE action = 'unassign';
+ALTER TYPE audit_action RENAME TO audit_action_old;
+CREATE TYPE audit_action AS ENUM ('create', 'update', 'delete', 'assign');
+ALTER TABLE audit_event ALTER COLUMN action TYPE audit_action USING action::text::audit_action;
+DROP TYPE audit_action_old;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE todo ADD COLUMN due_at TIMESTAMP;
+
+CREATE INDEX todo_due_at_idx ON todo (due_at);
+
+CREATE TABLE todo_reminder (
+    id SERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    offset_seconds INTEGER NOT NULL CHECK (offset_seconds >= 0),
+    sent_at TIMESTAMP,
+    FOREIGN KEY (todo_id) REFERENCES todo(id) ON DELETE CASCADE,
+    UNIQUE (todo_id, offset_seconds)
+);
+
+CREATE INDEX todo_reminder_unsent_idx ON todo_reminder (todo_id) WHERE sent_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE todo_reminder;
+
+ALTER TABLE todo DROP COLUMN due_at;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE todo_priority AS ENUM ('none', 'low', 'medium', 'high', 'urgent');
+
+ALTER TABLE todo ADD COLUMN priority todo_priority DEFAULT 'none' NOT NULL;
+
+CREATE INDEX todo_priority_idx ON todo (priority);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE todo DROP COLUMN priority;
+
+DROP TYPE todo_priority;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE label (
+    id SERIAL PRIMARY KEY,
+    creator_id INTEGER,
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7) NOT NULL CHECK (color ~ '^#[0-9a-f]{6}$'),
+    FOREIGN KEY (creator_id) REFERENCES "user"(id) ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX label_name_idx ON label (lower(name));
+
+CREATE TABLE todo_label (
+    todo_id INTEGER NOT NULL,
+    label_id INTEGER NOT NULL,
+    FOREIGN KEY (todo_id) REFERENCES todo(id) ON DELETE CASCADE,
+    FOREIGN KEY (label_id) REFERENCES label(id) ON DELETE CASCADE,
+    PRIMARY KEY (todo_id, label_id)
+);
+
+CREATE INDEX todo_label_label_id_idx ON todo_label (label_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE todo_label;
+DROP TABLE label;
+-- +goose StatementEnd
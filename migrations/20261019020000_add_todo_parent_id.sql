@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE todo ADD COLUMN parent_id INTEGER;
+
+ALTER TABLE todo ADD CONSTRAINT todo_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES todo(id) ON DELETE CASCADE;
+
+ALTER TABLE todo ADD CONSTRAINT todo_parent_id_check CHECK (parent_id <> id);
+
+CREATE INDEX todo_parent_id_idx ON todo (parent_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE todo DROP COLUMN parent_id;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE todo_comment (
+    id SERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    body VARCHAR(5000) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    FOREIGN KEY (todo_id) REFERENCES todo(id) ON DELETE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+
+CREATE INDEX todo_comment_todo_id_idx ON todo_comment (todo_id, id) WHERE deleted_at IS NULL;
+CREATE INDEX todo_comment_author_id_idx ON todo_comment (author_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE todo_comment;
+-- +goose StatementEnd
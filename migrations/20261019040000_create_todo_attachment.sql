@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE todo_attachment (
+    id SERIAL PRIMARY KEY,
+    todo_id INTEGER NOT NULL,
+    uploader_id INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes >= 0),
+    storage_key VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    FOREIGN KEY (todo_id) REFERENCES todo(id) ON DELETE CASCADE,
+    FOREIGN KEY (uploader_id) REFERENCES "user"(id) ON DELETE CASCADE
+);
+
+CREATE INDEX todo_attachment_todo_id_idx ON todo_attachment (todo_id);
+CREATE INDEX todo_attachment_uploader_id_idx ON todo_attachment (uploader_id);
+
+-- Attachments are also deleted by cascades from todos and users, so their
+-- blobs are queued here and removed from the blob store in the background.
+CREATE TABLE orphaned_blob (
+    storage_key VARCHAR(64) PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE FUNCTION queue_orphaned_blob() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO orphaned_blob (storage_key) VALUES (OLD.storage_key)
+    ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_attachment_orphaned_blob
+AFTER DELETE ON todo_attachment
+FOR EACH ROW EXECUTE FUNCTION queue_orphaned_blob();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE todo_attachment;
+DROP FUNCTION queue_orphaned_blob;
+DROP TABLE orphaned_blob;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- name: CreateApiKey :one
+INSERT INTO api_key (user_id, name, prefix, key_hash)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListApiKeysOfUser :many
+SELECT * FROM api_key
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetActiveApiKeyByPrefix :one
+SELECT api_key.*, "user".role FROM api_key
+JOIN "user" ON api_key.user_id = "user".id
+WHERE api_key.prefix = $1 AND api_key.revoked_at IS NULL LIMIT 1;
+
+-- name: TouchApiKey :exec
+UPDATE api_key
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RevokeApiKey :one
+UPDATE api_key
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+RETURNING *;
@@ -0,0 +1,15 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_event (actor_id, action, entity_type, entity_id, before_state, after_state)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_event
+WHERE (sqlc.narg(actor_id)::int IS NULL OR actor_id = sqlc.narg(actor_id))
+    AND (sqlc.narg(action)::audit_action IS NULL OR action = sqlc.narg(action))
+    AND (sqlc.narg(entity_type)::text IS NULL OR entity_type = sqlc.narg(entity_type))
+    AND (sqlc.narg(entity_id)::int IS NULL OR entity_id = sqlc.narg(entity_id))
+    AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+    AND (sqlc.narg(cursor)::int IS NULL OR id < sqlc.narg(cursor))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,41 @@
+-- name: ListLabels :many
+SELECT * FROM label
+ORDER BY lower(name);
+
+-- name: GetLabel :one
+SELECT * FROM label
+WHERE id = $1 LIMIT 1;
+
+-- name: CreateLabel :one
+INSERT INTO label (creator_id, name, color)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: UpdateLabel :one
+UPDATE label
+SET name = $1, color = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteLabel :execrows
+DELETE FROM label
+WHERE id = $1;
+
+-- name: AttachLabelToTodo :exec
+INSERT INTO todo_label (todo_id, label_id)
+VALUES ($1, $2);
+
+-- name: DetachLabelFromTodo :execrows
+DELETE FROM todo_label
+WHERE todo_id = $1 AND label_id = $2;
+
+-- name: ListTodoLabelsForDeletion :many
+SELECT * FROM todo_label
+WHERE todo_id = ANY(sqlc.arg(todo_ids)::int[])
+ORDER BY todo_id, label_id;
+
+-- name: ListLabelsOfCreator :many
+SELECT * FROM label
+WHERE creator_id = sqlc.arg(creator_id)::int
+ORDER BY id
+FOR UPDATE;
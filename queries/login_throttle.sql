@@ -0,0 +1,40 @@
+-- name: GetLoginLockSeconds :one
+SELECT COALESCE(MAX(CEIL(EXTRACT(EPOCH FROM locked_until - CURRENT_TIMESTAMP))), 0)::int FROM login_throttle
+WHERE key = ANY(sqlc.arg(keys)::text[]) AND locked_until > CURRENT_TIMESTAMP;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttle (key, failures, last_failure_at)
+VALUES ($1, 1, CURRENT_TIMESTAMP)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttle.last_failure_at < CURRENT_TIMESTAMP - (sqlc.arg(window_seconds)::int * INTERVAL '1 second') THEN 1
+        ELSE login_throttle.failures + 1
+    END,
+    last_failure_at = CURRENT_TIMESTAMP
+RETURNING failures;
+
+-- name: LockLogin :one
+UPDATE login_throttle
+SET locked_until = CURRENT_TIMESTAMP + (sqlc.arg(lock_seconds)::int * INTERVAL '1 second')
+WHERE key = $1
+RETURNING locked_until;
+
+-- name: ResetLoginThrottle :one
+DELETE FROM login_throttle
+WHERE key = $1
+RETURNING failures;
+
+-- name: ListLockedUsers :many
+SELECT "user".id, "user".username, login_throttle.failures, login_throttle.locked_until FROM login_throttle
+JOIN "user" ON login_throttle.key = 'user:' || "user".id
+WHERE login_throttle.locked_until > CURRENT_TIMESTAMP
+ORDER BY login_throttle.locked_until DESC;
+
+-- name: DeleteStaleLoginThrottles :execrows
+DELETE FROM login_throttle
+WHERE last_failure_at < CURRENT_TIMESTAMP - (sqlc.arg(window_seconds)::int * INTERVAL '1 second')
+AND (locked_until IS NULL OR locked_until < CURRENT_TIMESTAMP);
+
+-- name: CreateLockoutEvent :exec
+INSERT INTO lockout_event (action, key, user_id, actor_id, ip, failures, locked_until)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
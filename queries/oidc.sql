@@ -0,0 +1,21 @@
+-- name: CreateOidcAuthRequest :exec
+INSERT INTO oidc_auth_request (state, provider, nonce, code_verifier, expires_at)
+VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + (sqlc.arg(ttl_seconds)::int * INTERVAL '1 second'));
+
+-- name: TakeOidcAuthRequest :one
+DELETE FROM oidc_auth_request
+WHERE state = $1 AND provider = $2 AND expires_at > CURRENT_TIMESTAMP
+RETURNING nonce, code_verifier;
+
+-- name: DeleteExpiredOidcAuthRequests :execrows
+DELETE FROM oidc_auth_request
+WHERE expires_at < CURRENT_TIMESTAMP;
+
+-- name: GetUserByIdentity :one
+SELECT "user".* FROM "user"
+JOIN user_identity ON "user".id = user_identity.user_id
+WHERE user_identity.provider = $1 AND user_identity.subject = $2 LIMIT 1;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identity (user_id, provider, subject)
+VALUES ($1, $2, $3);
@@ -0,0 +1,18 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_token (user_id, token_hash, expires_at)
+VALUES ($1, $2, CURRENT_TIMESTAMP + (sqlc.arg(ttl_seconds)::int * INTERVAL '1 second'));
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_token
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+RETURNING user_id;
+
+-- name: InvalidateUserPasswordResetTokens :exec
+UPDATE password_reset_token
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteExpiredPasswordResetTokens :execrows
+DELETE FROM password_reset_token
+WHERE expires_at < CURRENT_TIMESTAMP;
@@ -0,0 +1,17 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_bucket AS bucket (key, tokens, updated_at)
+VALUES (@key, @burst::double precision - 1, CURRENT_TIMESTAMP)
+ON CONFLICT (key) DO UPDATE
+SET tokens = LEAST(@burst::double precision, bucket.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - bucket.updated_at)::double precision * @rate::double precision) - 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE LEAST(@burst::double precision, bucket.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - bucket.updated_at)::double precision * @rate::double precision) >= 1
+RETURNING tokens;
+
+-- name: GetRateLimitTokens :one
+SELECT LEAST(@burst::double precision, tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - updated_at)::double precision * @rate::double precision)::double precision AS tokens
+FROM rate_limit_bucket
+WHERE key = @key;
+
+-- name: DeleteIdleRateLimitBuckets :execrows
+DELETE FROM rate_limit_bucket
+WHERE updated_at < CURRENT_TIMESTAMP - (sqlc.arg(idle_seconds)::int * INTERVAL '1 second');
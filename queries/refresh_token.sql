@@ -0,0 +1,28 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_token (user_id, family_id, token_hash, expires_at)
+VALUES ($1, $2, $3, CURRENT_TIMESTAMP + (sqlc.arg(ttl_seconds)::int * INTERVAL '1 second'))
+RETURNING *;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT *, expires_at < CURRENT_TIMESTAMP AS expired FROM refresh_token
+WHERE token_hash = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_token
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_token
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_token
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_token
+WHERE expires_at < CURRENT_TIMESTAMP;
@@ -0,0 +1,21 @@
+-- name: CreateSession :one
+INSERT INTO session (user_id, token_hash, csrf_token, expires_at)
+VALUES ($1, $2, $3, CURRENT_TIMESTAMP + (sqlc.arg(ttl_seconds)::int * INTERVAL '1 second'))
+RETURNING *;
+
+-- name: GetActiveSession :one
+SELECT session.id, session.user_id, session.csrf_token, session.expires_at, "user".role FROM session
+JOIN "user" ON session.user_id = "user".id
+WHERE session.token_hash = $1 AND session.expires_at > CURRENT_TIMESTAMP LIMIT 1;
+
+-- name: DeleteSession :exec
+DELETE FROM session
+WHERE token_hash = $1;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM session
+WHERE user_id = $1;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM session
+WHERE expires_at < CURRENT_TIMESTAMP;
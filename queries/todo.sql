@@ -1,36 +1,221 @@
 -- name: ListTodos :many
 SELECT * FROM todo
-ORDER BY created_at DESC;
+WHERE (sqlc.narg(priority)::todo_priority IS NULL OR todo.priority = sqlc.narg(priority)::todo_priority)
+    AND (cardinality(sqlc.arg(label_ids)::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY(sqlc.arg(label_ids)::int[])
+    ) >= CASE WHEN sqlc.arg(match_all_labels)::bool THEN cardinality(sqlc.arg(label_ids)::int[]) ELSE 1 END)
+ORDER BY CASE WHEN sqlc.arg(sort)::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC;
+
+-- name: GetTodo :one
+SELECT * FROM todo
+WHERE id = $1 LIMIT 1;
+
+-- name: GetTodoForUpdate :one
+SELECT * FROM todo
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: GetTodoWithUsers :one
+SELECT sqlc.embed(todo), sqlc.embed(creator), COALESCE((
+    SELECT json_agg(json_build_object(
+        'id', assignee.id,
+        'username', assignee.username,
+        'email', assignee.email,
+        'email_verified', assignee.email_verified_at IS NOT NULL,
+        'role', assignee.role
+    ) ORDER BY assignee.id)
+    FROM todo_user
+    JOIN "user" AS assignee ON todo_user.user_id = assignee.id
+    WHERE todo_user.todo_id = todo.id
+), '[]')::json AS assignees, COALESCE((
+    SELECT array_agg(todo_reminder.offset_seconds ORDER BY todo_reminder.offset_seconds)
+    FROM todo_reminder
+    WHERE todo_reminder.todo_id = todo.id
+), '{}')::int[] AS reminders, COALESCE((
+    SELECT json_agg(label ORDER BY lower(label.name))
+    FROM todo_label
+    JOIN label ON todo_label.label_id = label.id
+    WHERE todo_label.todo_id = todo.id
+), '[]')::json AS labels, (
+    SELECT count(*) FROM todo AS subtask
+    WHERE subtask.parent_id = todo.id
+) AS subtask_count, (
+    SELECT count(*) FROM todo AS subtask
+    WHERE subtask.parent_id = todo.id AND subtask.completed
+) AS completed_subtask_count
+FROM todo
+JOIN "user" AS creator ON todo.creator_id = creator.id
+WHERE todo.id = $1;
 
 -- name: GetAllTodosOfUser :many
 SELECT todo.* FROM todo
 LEFT JOIN todo_user ON todo.id = todo_user.todo_id
-WHERE todo_user.user_id = $1 OR todo.creator_id = $1;
+WHERE (todo_user.user_id = sqlc.arg(user_id) OR todo.creator_id = sqlc.arg(user_id))
+    AND (sqlc.narg(overdue)::bool IS NULL OR COALESCE(todo.due_at < CURRENT_TIMESTAMP AND NOT todo.completed, false) = sqlc.narg(overdue)::bool)
+    AND (sqlc.narg(priority)::todo_priority IS NULL OR todo.priority = sqlc.narg(priority)::todo_priority)
+    AND (cardinality(sqlc.arg(label_ids)::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY(sqlc.arg(label_ids)::int[])
+    ) >= CASE WHEN sqlc.arg(match_all_labels)::bool THEN cardinality(sqlc.arg(label_ids)::int[]) ELSE 1 END)
+ORDER BY CASE WHEN sqlc.arg(sort)::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC;
 
 -- name: GetCreatedTodosOfUser :many
 SELECT * FROM todo
-WHERE todo.creator_id = $1;
+WHERE todo.creator_id = sqlc.arg(creator_id)
+    AND (sqlc.narg(overdue)::bool IS NULL OR COALESCE(todo.due_at < CURRENT_TIMESTAMP AND NOT todo.completed, false) = sqlc.narg(overdue)::bool)
+    AND (sqlc.narg(priority)::todo_priority IS NULL OR todo.priority = sqlc.narg(priority)::todo_priority)
+    AND (cardinality(sqlc.arg(label_ids)::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY(sqlc.arg(label_ids)::int[])
+    ) >= CASE WHEN sqlc.arg(match_all_labels)::bool THEN cardinality(sqlc.arg(label_ids)::int[]) ELSE 1 END)
+ORDER BY CASE WHEN sqlc.arg(sort)::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC;
 
 -- name: GetAssignedTodosOfUser :many
 SELECT todo.* FROM todo
 JOIN todo_user ON todo.id = todo_user.todo_id
-WHERE todo_user.user_id = $1;
+WHERE todo_user.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(overdue)::bool IS NULL OR COALESCE(todo.due_at < CURRENT_TIMESTAMP AND NOT todo.completed, false) = sqlc.narg(overdue)::bool)
+    AND (sqlc.narg(priority)::todo_priority IS NULL OR todo.priority = sqlc.narg(priority)::todo_priority)
+    AND (cardinality(sqlc.arg(label_ids)::int[]) = 0 OR (
+        SELECT count(*) FROM todo_label
+        WHERE todo_label.todo_id = todo.id AND todo_label.label_id = ANY(sqlc.arg(label_ids)::int[])
+    ) >= CASE WHEN sqlc.arg(match_all_labels)::bool THEN cardinality(sqlc.arg(label_ids)::int[]) ELSE 1 END)
+ORDER BY CASE WHEN sqlc.arg(sort)::text = 'priority' THEN todo.priority END DESC, todo.created_at DESC;
 
 -- name: CreateTodo :one
-INSERT INTO todo (title, description, creator_id)
-VALUES ($1, $2, $3)
+INSERT INTO todo (title, description, creator_id, due_at, priority, parent_id)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListSubtasks :many
+SELECT * FROM todo
+WHERE parent_id = $1
+ORDER BY created_at;
+
+-- name: GetTodoTree :many
+WITH RECURSIVE tree AS (
+    SELECT todo.id, 0 AS depth FROM todo
+    WHERE todo.id = $1
+    UNION ALL
+    SELECT todo.id, tree.depth + 1 FROM todo
+    JOIN tree ON todo.parent_id = tree.id
+)
+SELECT sqlc.embed(todo), tree.depth::int AS depth FROM tree
+JOIN todo ON todo.id = tree.id
+ORDER BY tree.depth, todo.created_at;
+
+-- name: ListTodoAncestorIDs :many
+WITH RECURSIVE ancestor AS (
+    SELECT todo.id, todo.parent_id, 0 AS depth FROM todo
+    WHERE todo.id = $1
+    UNION ALL
+    SELECT todo.id, todo.parent_id, ancestor.depth + 1 FROM todo
+    JOIN ancestor ON todo.id = ancestor.parent_id
+)
+SELECT id FROM ancestor
+ORDER BY depth;
+
+-- name: ListTodoTreesOfCreator :many
+WITH RECURSIVE tree AS (
+    SELECT todo.id FROM todo
+    WHERE todo.creator_id = $1
+    UNION
+    SELECT todo.id FROM todo
+    JOIN tree ON todo.parent_id = tree.id
+)
+SELECT * FROM todo
+WHERE id IN (SELECT id FROM tree)
+ORDER BY id;
+
+-- name: LockTodos :many
+SELECT * FROM todo
+WHERE id = ANY(sqlc.arg(ids)::int[])
+ORDER BY id
+FOR UPDATE;
+
+-- name: ListTodoRootIDs :many
+WITH RECURSIVE ancestor AS (
+    SELECT todo.id, todo.parent_id FROM todo
+    WHERE todo.id = ANY(sqlc.arg(ids)::int[])
+    UNION
+    SELECT todo.id, todo.parent_id FROM todo
+    JOIN ancestor ON todo.id = ancestor.parent_id
+)
+SELECT id FROM ancestor
+WHERE parent_id IS NULL
+ORDER BY id;
+
+-- name: LockTodoTrees :exec
+SELECT pg_advisory_xact_lock(hashtext('todo_tree'), root.id)
+FROM (
+    SELECT unnest(sqlc.arg(root_ids)::int[]) AS id
+    ORDER BY id
+) AS root;
+
+-- name: SetTodoParent :one
+UPDATE todo
+SET parent_id = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+RETURNING *;
+
+-- name: CompleteTodoIfSubtasksDone :one
+UPDATE todo
+SET completed = true, updated_at = CURRENT_TIMESTAMP
+WHERE todo.id = $1 AND NOT todo.completed AND NOT EXISTS (
+    SELECT 1 FROM todo AS subtask
+    WHERE subtask.parent_id = todo.id AND NOT subtask.completed
+)
 RETURNING *;
 
 -- name: AssignUserToTodo :execrows
 INSERT INTO todo_user (todo_id, user_id)
-VALUES ($1, $2);
+SELECT sqlc.arg(todo_id)::int, "user".id FROM "user"
+WHERE "user".id = sqlc.arg(user_id) AND "user".email_verified_at IS NOT NULL;
+
+-- name: ListTodoAssignees :many
+SELECT "user".* FROM "user"
+JOIN todo_user ON "user".id = todo_user.user_id
+WHERE todo_user.todo_id = $1
+ORDER BY "user".id;
+
+-- name: ListTodoAssignmentsForDeletion :many
+SELECT * FROM todo_user
+WHERE todo_id = ANY(sqlc.arg(todo_ids)::int[]) OR user_id = sqlc.narg(user_id)::int
+ORDER BY todo_id, user_id;
+
+-- name: UnassignUserFromTodo :execrows
+DELETE FROM todo_user
+WHERE todo_id = $1 AND user_id = $2;
 
 -- name: UpdateTodo :one
 UPDATE todo
-SET title = $1, description = $2, completed = $3
-WHERE id = $4
+SET title = $1, description = $2, completed = $3, due_at = $4, priority = $5, updated_at = CURRENT_TIMESTAMP
+WHERE id = $6
 RETURNING *;
 
 -- name: DeleteTodo :execrows
 DELETE FROM todo
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetTodoAccess :one
+WITH RECURSIVE ancestor AS (
+    SELECT parent.id, parent.parent_id, parent.creator_id FROM todo
+    JOIN todo AS parent ON parent.id = todo.parent_id
+    WHERE todo.id = $1
+    UNION ALL
+    SELECT parent.id, parent.parent_id, parent.creator_id FROM todo AS parent
+    JOIN ancestor ON parent.id = ancestor.parent_id
+)
+SELECT todo.creator_id, EXISTS (
+    SELECT 1 FROM todo_user
+    WHERE todo_user.todo_id = todo.id AND todo_user.user_id = $2
+) AS assigned, EXISTS (
+    SELECT 1 FROM ancestor
+    WHERE ancestor.creator_id = $2 OR EXISTS (
+        SELECT 1 FROM todo_user
+        WHERE todo_user.todo_id = ancestor.id AND todo_user.user_id = $2
+    )
+) AS inherited
+FROM todo
+WHERE todo.id = $1;
@@ -0,0 +1,37 @@
+-- name: ListTodoAttachments :many
+SELECT * FROM todo_attachment
+WHERE todo_id = $1
+ORDER BY id;
+
+-- name: GetTodoAttachment :one
+SELECT * FROM todo_attachment
+WHERE id = $1 AND todo_id = $2 LIMIT 1;
+
+-- name: CreateTodoAttachment :one
+INSERT INTO todo_attachment (todo_id, uploader_id, file_name, content_type, size_bytes, storage_key)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: DeleteTodoAttachment :execrows
+DELETE FROM todo_attachment
+WHERE id = $1;
+
+-- name: ListTodoAttachmentsForDeletion :many
+SELECT * FROM todo_attachment
+WHERE todo_id = ANY(sqlc.arg(todo_ids)::int[]) OR uploader_id = sqlc.narg(uploader_id)::int
+ORDER BY id
+FOR UPDATE;
+
+-- name: QueueOrphanedBlob :exec
+INSERT INTO orphaned_blob (storage_key)
+VALUES ($1);
+
+-- name: ListOrphanedBlobs :many
+SELECT storage_key FROM orphaned_blob
+WHERE created_at < CURRENT_TIMESTAMP - (sqlc.arg(min_age_seconds)::int * INTERVAL '1 second')
+ORDER BY created_at
+LIMIT sqlc.arg(batch_size);
+
+-- name: DeleteOrphanedBlob :exec
+DELETE FROM orphaned_blob
+WHERE storage_key = $1;
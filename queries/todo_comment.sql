@@ -0,0 +1,33 @@
+-- name: ListTodoComments :many
+SELECT * FROM todo_comment
+WHERE todo_id = sqlc.arg(todo_id) AND deleted_at IS NULL
+    AND (sqlc.narg(cursor)::int IS NULL OR id > sqlc.narg(cursor))
+ORDER BY id
+LIMIT sqlc.arg(page_size);
+
+-- name: GetTodoComment :one
+SELECT * FROM todo_comment
+WHERE id = $1 AND todo_id = $2 AND deleted_at IS NULL LIMIT 1;
+
+-- name: CreateTodoComment :one
+INSERT INTO todo_comment (todo_id, author_id, body)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: UpdateTodoComment :one
+UPDATE todo_comment
+SET body = $1, edited_at = CURRENT_TIMESTAMP
+WHERE id = $2 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeleteTodoComment :execrows
+UPDATE todo_comment
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ListTodoCommentsForDeletion :many
+SELECT * FROM todo_comment
+WHERE deleted_at IS NULL
+    AND (todo_id = ANY(sqlc.arg(todo_ids)::int[]) OR author_id = sqlc.narg(author_id)::int)
+ORDER BY id
+FOR UPDATE;
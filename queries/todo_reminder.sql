@@ -0,0 +1,44 @@
+-- name: ListTodoReminders :many
+SELECT offset_seconds FROM todo_reminder
+WHERE todo_id = $1
+ORDER BY offset_seconds;
+
+-- name: CreateTodoReminders :exec
+INSERT INTO todo_reminder (todo_id, offset_seconds)
+SELECT sqlc.arg(todo_id)::int, unnest(sqlc.arg(offsets)::int[])
+ON CONFLICT (todo_id, offset_seconds) DO NOTHING;
+
+-- name: DeleteTodoRemindersNotIn :exec
+DELETE FROM todo_reminder
+WHERE todo_id = sqlc.arg(todo_id) AND NOT (offset_seconds = ANY(sqlc.arg(offsets)::int[]));
+
+-- name: ResetTodoReminders :exec
+UPDATE todo_reminder
+SET sent_at = NULL
+WHERE todo_id = $1;
+
+-- name: ClaimDueTodoReminders :many
+UPDATE todo_reminder
+SET sent_at = CURRENT_TIMESTAMP
+FROM todo
+WHERE todo_reminder.todo_id = todo.id AND todo_reminder.id IN (
+    SELECT due.id FROM todo_reminder AS due
+    JOIN todo ON due.todo_id = todo.id
+    WHERE due.sent_at IS NULL
+        AND NOT todo.completed
+        AND todo.due_at - (due.offset_seconds * INTERVAL '1 second') <= CURRENT_TIMESTAMP
+        AND todo.due_at > CURRENT_TIMESTAMP
+    ORDER BY todo.due_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE OF due SKIP LOCKED
+)
+RETURNING todo_reminder.id, todo_reminder.todo_id, todo_reminder.offset_seconds, todo.title, todo.due_at;
+
+-- name: ListTodoRecipients :many
+SELECT "user".* FROM "user"
+WHERE "user".id IN (
+    SELECT todo.creator_id FROM todo WHERE todo.id = sqlc.arg(todo_id)
+    UNION
+    SELECT todo_user.user_id FROM todo_user WHERE todo_user.todo_id = sqlc.arg(todo_id)
+)
+ORDER BY "user".id;
@@ -2,13 +2,18 @@
 SELECT * FROM "user"
 WHERE id = $1 LIMIT 1;
 
+-- name: GetUserForUpdate :one
+SELECT * FROM "user"
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
 -- name: ListUsers :many
 SELECT * FROM "user"
 ORDER BY username;
 
 -- name: CreateUser :one
 INSERT INTO "user" (
-  username, email, password
+  username, email, password_hash
 ) VALUES (
   $1, $2, $3
 )
@@ -18,10 +23,36 @@ RETURNING *;
 UPDATE "user"
   set username = $2,
   email = $3,
-  password = $4
+  password_hash = $4,
+  email_verified_at = CASE WHEN LOWER(email) = LOWER($3) THEN email_verified_at END,
+  updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
 RETURNING *;
 
 -- name: DeleteUser :execrows
 DELETE FROM "user"
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetUserByUsername :one
+SELECT * FROM "user"
+WHERE LOWER(username) = LOWER(sqlc.arg(username)) LIMIT 1;
+
+-- name: UpdateUserPassword :exec
+UPDATE "user"
+  set password_hash = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: UpdateUserRole :one
+UPDATE "user"
+  set role = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM "user"
+WHERE LOWER(email) = LOWER(sqlc.arg(email)) LIMIT 1;
+
+-- name: VerifyUserEmail :execrows
+UPDATE "user"
+  set email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+WHERE id = sqlc.arg(id) AND LOWER(email) = LOWER(sqlc.arg(email));